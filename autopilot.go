@@ -0,0 +1,299 @@
+package easyraft
+
+import (
+	"context"
+	"errors"
+	"github.com/hashicorp/raft"
+	"github.com/ksrichard/easyraft/grpc"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAutopilotInterval     = time.Second
+	defaultLastContactThreshold  = 5 * time.Second
+	defaultMaxLag                = 250
+	defaultDeadServerGracePeriod = time.Minute
+	minAutopilotPollTimeout      = 100 * time.Millisecond
+)
+
+// ErrAutopilotDisabled is returned when the cluster health is requested without autopilot enabled
+var ErrAutopilotDisabled = errors.New("autopilot is disabled")
+
+// AutopilotConfig configures the health tracking and the dead server cleanup done by the leader
+type AutopilotConfig struct {
+	// Interval is how often the leader checks the servers (default: 1s)
+	Interval time.Duration
+
+	// LastContactThreshold is the maximum time since the last successful contact of a healthy server (default: 5s)
+	LastContactThreshold time.Duration
+
+	// MaxLag is the maximum number of raft logs a healthy server can be behind the leader (default: 250)
+	MaxLag uint64
+
+	// CleanupDeadServers enables removing the servers which were not reachable for DeadServerGracePeriod
+	CleanupDeadServers bool
+
+	// DeadServerGracePeriod is the time after an unreachable server is considered dead (default: 1m)
+	DeadServerGracePeriod time.Duration
+}
+
+// setDefaults fills all the unset settings with their default values
+func (c *AutopilotConfig) setDefaults() {
+	if c.Interval <= 0 {
+		c.Interval = defaultAutopilotInterval
+	}
+	if c.LastContactThreshold <= 0 {
+		c.LastContactThreshold = defaultLastContactThreshold
+	}
+	if c.MaxLag == 0 {
+		c.MaxLag = defaultMaxLag
+	}
+	if c.DeadServerGracePeriod <= 0 {
+		c.DeadServerGracePeriod = defaultDeadServerGracePeriod
+	}
+}
+
+// ServerHealth is the health of a server of the raft cluster as seen by the leader
+type ServerHealth struct {
+	ID       string
+	Address  string
+	Suffrage string
+
+	// Healthy is true when the server was reachable within the last contact threshold and isn't lagging behind
+	Healthy bool
+
+	// LastContact is the time of the last successful contact, the time the leader started tracking the server
+	// if it has never been reachable
+	LastContact time.Time
+
+	// LastIndex is the last raft log index stored by the server
+	LastIndex uint64
+
+	// Lag is the number of raft logs the server is behind the leader
+	Lag uint64
+
+	// StableSince is the time since the server has its actual health state
+	StableSince time.Time
+}
+
+// autopilot tracks the health of the servers while the Node is the leader
+type autopilot struct {
+	sync.Mutex
+	config  AutopilotConfig
+	servers map[raft.ServerID]*ServerHealth
+	stopCh  chan struct{}
+}
+
+func newAutopilot(config AutopilotConfig) *autopilot {
+	config.setDefaults()
+	return &autopilot{
+		config:  config,
+		servers: map[raft.ServerID]*ServerHealth{},
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// ClusterHealth returns the health of every server of the cluster, it's only available on the leader
+// when autopilot is enabled
+func (n *Node) ClusterHealth() ([]ServerHealth, error) {
+	if n.autopilot == nil {
+		return nil, ErrAutopilotDisabled
+	}
+	if !n.IsLeader() {
+		return nil, raft.ErrNotLeader
+	}
+	n.autopilot.Lock()
+	defer n.autopilot.Unlock()
+	health := make([]ServerHealth, 0, len(n.autopilot.servers))
+	for _, server := range n.autopilot.servers {
+		health = append(health, *server)
+	}
+	sort.Slice(health, func(i, j int) bool {
+		return health[i].ID < health[j].ID
+	})
+	return health, nil
+}
+
+// runAutopilot checks the servers periodically while this Node is the leader, until autopilot is stopped
+func (n *Node) runAutopilot() {
+	ticker := time.NewTicker(n.autopilot.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.autopilot.stopCh:
+			return
+		case <-ticker.C:
+			if n.Raft.State() != raft.Leader {
+				n.autopilot.reset()
+				continue
+			}
+			n.updateServerHealth()
+			if n.autopilot.config.CleanupDeadServers {
+				n.removeDeadServers()
+			}
+		}
+	}
+}
+
+// reset forgets the tracked servers, the tracking starts over when the Node becomes the leader again
+func (a *autopilot) reset() {
+	a.Lock()
+	defer a.Unlock()
+	a.servers = map[raft.ServerID]*ServerHealth{}
+}
+
+// stop stops the autopilot loop
+func (a *autopilot) stop() {
+	close(a.stopCh)
+}
+
+// updateServerHealth polls the details of every server and updates their health
+func (n *Node) updateServerHealth() {
+	future := n.Raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		n.logger.Printf("Autopilot failed to get raft configuration: %q\n", err.Error())
+		return
+	}
+	servers := future.Configuration().Servers
+	details := make([]*grpc.GetDetailsResponse, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		if server.ID == raft.ServerID(n.ID) {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, address raft.ServerAddress) {
+			defer wg.Done()
+			details[i] = n.pollPeerDetails(string(address))
+		}(i, server.Address)
+	}
+	wg.Wait()
+
+	leaderIndex := n.Raft.LastIndex()
+	now := time.Now()
+	n.autopilot.Lock()
+	defer n.autopilot.Unlock()
+	tracked := map[raft.ServerID]*ServerHealth{}
+	for i, server := range servers {
+		health, ok := n.autopilot.servers[server.ID]
+		if !ok {
+			health = &ServerHealth{LastContact: now, StableSince: now}
+		}
+		health.ID = string(server.ID)
+		health.Address = string(server.Address)
+		health.Suffrage = server.Suffrage.String()
+		if server.ID == raft.ServerID(n.ID) {
+			health.LastContact = now
+			health.LastIndex = leaderIndex
+		} else if details[i] != nil {
+			health.LastContact = now
+			health.LastIndex = details[i].LastIndex
+		}
+		health.Lag = 0
+		if leaderIndex > health.LastIndex {
+			health.Lag = leaderIndex - health.LastIndex
+		}
+		healthy := now.Sub(health.LastContact) <= n.autopilot.config.LastContactThreshold &&
+			health.Lag <= n.autopilot.config.MaxLag
+		if healthy != health.Healthy || !ok {
+			health.StableSince = now
+			if ok {
+				n.logger.Printf("Server %s is healthy: %t\n", health.ID, healthy)
+			}
+		}
+		health.Healthy = healthy
+		tracked[server.ID] = health
+	}
+	n.autopilot.servers = tracked
+}
+
+// pollPeerDetails returns the details of the given peer, or nil if it's not reachable within half of the interval
+func (n *Node) pollPeerDetails(address string) *grpc.GetDetailsResponse {
+	timeout := n.autopilot.config.Interval / 2
+	if timeout < minAutopilotPollTimeout {
+		timeout = minAutopilotPollTimeout
+	}
+	conn, err := n.connPool.get(address)
+	if err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	details, err := grpc.NewRaftClient(conn).GetDetails(ctx, &grpc.GetDetailsRequest{})
+	if err != nil {
+		return nil
+	}
+	return details
+}
+
+// removeDeadServers removes the servers which were unreachable for the grace period,
+// voters are removed only while the remaining healthy voters still form a quorum
+func (n *Node) removeDeadServers() {
+	n.autopilot.Lock()
+	removable, kept := removableDeadServers(n.autopilot.servers, n.ID, n.autopilot.config.DeadServerGracePeriod, time.Now())
+	n.autopilot.Unlock()
+
+	for _, server := range kept {
+		n.logger.Printf("Not removing dead server %s, the remaining healthy voters wouldn't form a quorum\n", server.ID)
+	}
+	removed := false
+	for _, server := range removable {
+		err := n.Raft.RemoveServer(raft.ServerID(server.ID), 0, 0).Error()
+		if err != nil {
+			n.logger.Printf("Failed to remove dead server %s: %q\n", server.ID, err.Error())
+			continue
+		}
+		n.logger.Printf("Removed dead server %s\n", server.ID)
+		n.publishEvent(PeerRemoved, server.ID, server.Address)
+		n.connPool.invalidate(server.Address, n.config.ForwardTimeout)
+		removed = true
+
+		n.autopilot.Lock()
+		delete(n.autopilot.servers, raft.ServerID(server.ID))
+		n.autopilot.Unlock()
+	}
+	if removed {
+		n.reconcileVoters()
+	}
+}
+
+// removableDeadServers returns the servers (apart from selfId) which were unreachable for the grace period sorted by ID,
+// split into the removable ones and the dead voters which are kept because the remaining healthy voters
+// wouldn't form a quorum without them
+func removableDeadServers(servers map[raft.ServerID]*ServerHealth, selfId string, gracePeriod time.Duration, now time.Time) ([]ServerHealth, []ServerHealth) {
+	var dead []ServerHealth
+	voters, healthyVoters := 0, 0
+	for _, server := range servers {
+		if server.Suffrage == raft.Voter.String() {
+			voters++
+			if server.Healthy {
+				healthyVoters++
+			}
+		}
+		if server.ID != selfId && now.Sub(server.LastContact) > gracePeriod {
+			dead = append(dead, *server)
+		}
+	}
+	sort.Slice(dead, func(i, j int) bool {
+		return dead[i].ID < dead[j].ID
+	})
+
+	var removable, kept []ServerHealth
+	for _, server := range dead {
+		if server.Suffrage == raft.Voter.String() {
+			if server.Healthy {
+				continue
+			}
+			remainingVoters := voters - 1
+			if healthyVoters < remainingVoters/2+1 {
+				kept = append(kept, server)
+				continue
+			}
+			voters = remainingVoters
+		}
+		removable = append(removable, server)
+	}
+	return removable, kept
+}
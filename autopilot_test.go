@@ -0,0 +1,107 @@
+package easyraft
+
+import (
+	"github.com/hashicorp/raft"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRemovableDeadServers(t *testing.T) {
+	now := time.Now()
+	gracePeriod := time.Minute
+	alive := now.Add(-time.Second)
+	dead := now.Add(-2 * gracePeriod)
+	voter := func(id string, healthy bool, lastContact time.Time) *ServerHealth {
+		return &ServerHealth{ID: id, Suffrage: raft.Voter.String(), Healthy: healthy, LastContact: lastContact}
+	}
+	nonvoter := func(id string, lastContact time.Time) *ServerHealth {
+		return &ServerHealth{ID: id, Suffrage: raft.Nonvoter.String(), LastContact: lastContact}
+	}
+
+	tests := []struct {
+		name          string
+		servers       []*ServerHealth
+		wantRemovable []string
+		wantKept      []string
+	}{
+		{
+			name:    "all servers alive",
+			servers: []*ServerHealth{voter("self", true, now), voter("b", true, alive), voter("c", true, alive)},
+		},
+		{
+			name:    "unreachable within the grace period",
+			servers: []*ServerHealth{voter("self", true, now), voter("b", true, alive), voter("c", false, now.Add(-gracePeriod/2))},
+		},
+		{
+			name:          "one dead voter of three",
+			servers:       []*ServerHealth{voter("self", true, now), voter("b", true, alive), voter("c", false, dead)},
+			wantRemovable: []string{"c"},
+		},
+		{
+			name:     "two dead voters of three",
+			servers:  []*ServerHealth{voter("self", true, now), voter("b", false, dead), voter("c", false, dead)},
+			wantKept: []string{"b", "c"},
+		},
+		{
+			name: "two dead voters of five",
+			servers: []*ServerHealth{
+				voter("self", true, now), voter("b", true, alive), voter("c", true, alive),
+				voter("d", false, dead), voter("e", false, dead),
+			},
+			wantRemovable: []string{"d", "e"},
+		},
+		{
+			name: "three dead voters of five",
+			servers: []*ServerHealth{
+				voter("self", true, now), voter("b", true, alive), voter("c", false, dead),
+				voter("d", false, dead), voter("e", false, dead),
+			},
+			wantKept: []string{"c", "d", "e"},
+		},
+		{
+			name: "two dead voters of four",
+			servers: []*ServerHealth{
+				voter("self", true, now), voter("b", true, alive), voter("c", false, dead), voter("d", false, dead),
+			},
+			wantRemovable: []string{"c", "d"},
+		},
+		{
+			name:          "dead nonvoter without a healthy quorum",
+			servers:       []*ServerHealth{voter("self", true, now), voter("b", false, dead), voter("c", false, dead), nonvoter("d", dead)},
+			wantRemovable: []string{"d"},
+			wantKept:      []string{"b", "c"},
+		},
+		{
+			name:    "healthy voter with an old last contact",
+			servers: []*ServerHealth{voter("self", true, now), voter("b", true, alive), voter("c", true, dead)},
+		},
+		{
+			name:    "self is never removed",
+			servers: []*ServerHealth{voter("self", true, dead), voter("b", true, alive), voter("c", true, alive)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servers := map[raft.ServerID]*ServerHealth{}
+			for _, server := range tt.servers {
+				servers[raft.ServerID(server.ID)] = server
+			}
+			removable, kept := removableDeadServers(servers, "self", gracePeriod, now)
+			if got := serverIds(removable); !reflect.DeepEqual(got, tt.wantRemovable) {
+				t.Errorf("removable = %v, want %v", got, tt.wantRemovable)
+			}
+			if got := serverIds(kept); !reflect.DeepEqual(got, tt.wantKept) {
+				t.Errorf("kept = %v, want %v", got, tt.wantKept)
+			}
+		})
+	}
+}
+
+func serverIds(servers []ServerHealth) []string {
+	var ids []string
+	for _, server := range servers {
+		ids = append(ids, server.ID)
+	}
+	return ids
+}
@@ -0,0 +1,126 @@
+package easyraft
+
+import (
+	"context"
+	"errors"
+	"github.com/hashicorp/raft"
+	"github.com/ksrichard/easyraft/fsm"
+	"github.com/ksrichard/easyraft/grpc"
+	"time"
+)
+
+// BatchResult is the result of a single request of a batch applied with RaftApplyBatch
+type BatchResult struct {
+	// Response is the response of the FSM service, decoded the same way as the response of RaftApply
+	Response interface{}
+
+	// Error is the error of the request, errors returned by the FSM services are fsm.ApplicationError
+	Error error
+}
+
+// RaftApplyBatch is used to apply multiple requests to the raft cluster with a single call,
+// every request is a separate raft log, the logs are appended in order and the results are returned in the same order
+// this method does automatic forwarding to Leader Node, the whole batch is forwarded with a single call
+func (n *Node) RaftApplyBatch(requests []interface{}, timeout time.Duration) ([]BatchResult, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return n.RaftApplyBatchContext(ctx, requests)
+}
+
+// RaftApplyBatchContext is the same as RaftApplyBatch, but it stops waiting for the results when the context is done,
+// if any of the requests can't be encoded, none of them is applied
+func (n *Node) RaftApplyBatchContext(ctx context.Context, requests []interface{}) ([]BatchResult, error) {
+	if len(requests) == 0 {
+		return []BatchResult{}, nil
+	}
+	payloads := make([][]byte, len(requests))
+	for i, request := range requests {
+		payload, err := n.fsm.EncodeCommand(request)
+		if err != nil {
+			return nil, err
+		}
+		payloads[i] = payload
+	}
+
+	if n.Raft.State() == raft.Leader {
+		results, err := n.applyLocalBatch(ctx, payloads)
+		if err != raft.ErrNotLeader {
+			return results, err
+		}
+	}
+
+	responses, err := applyBatchOnLeader(ctx, n, payloads)
+	if err != nil {
+		return nil, err
+	}
+	if len(responses) != len(requests) {
+		return nil, errors.New("invalid number of batch results received from leader")
+	}
+	results := make([]BatchResult, len(responses))
+	for i, response := range responses {
+		if response.Error != nil {
+			results[i].Error = applicationErrorFromProto(response.Error)
+			continue
+		}
+		results[i].Response, results[i].Error = n.fsm.DecodeResponse(requests[i], response.Response)
+	}
+	return results, nil
+}
+
+// applyLocalBatch applies already serialized requests on this Node, all the logs are enqueued before waiting
+// for the results, so raft can append and commit them together. When the context is done while enqueueing,
+// the remaining requests are not enqueued and get the error of the context. raft.ErrNotLeader is returned
+// only when none of the logs have been accepted.
+func (n *Node) applyLocalBatch(ctx context.Context, payloads [][]byte) ([]BatchResult, error) {
+	timeout, err := enqueueTimeout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	futures := make([]raft.ApplyFuture, 0, len(payloads))
+	for _, payload := range payloads {
+		if ctx.Err() != nil {
+			break
+		}
+		futures = append(futures, n.Raft.ApplyLog(raft.Log{Data: payload}, timeout))
+	}
+
+	results := make([]BatchResult, len(payloads))
+	notLeader := 0
+	for i := range results {
+		if i >= len(futures) {
+			results[i].Error = ctx.Err()
+			continue
+		}
+		results[i].Response, results[i].Error = waitApplied(ctx, futures[i])
+		if results[i].Error == raft.ErrNotLeader {
+			notLeader++
+		}
+	}
+	if notLeader == len(results) {
+		return nil, raft.ErrNotLeader
+	}
+	return results, nil
+}
+
+// batchResultsToProto serializes the results of a locally applied batch to be sent back to the forwarding Node,
+// errors which are not returned by the FSM services are sent with fsm.UnknownErrorCode
+func (n *Node) batchResultsToProto(results []BatchResult) []*grpc.ApplyResponse {
+	responses := make([]*grpc.ApplyResponse, len(results))
+	for i, result := range results {
+		err := result.Error
+		if err == nil {
+			var payload []byte
+			payload, err = n.Serializer.Serialize(result.Response)
+			if err == nil {
+				responses[i] = &grpc.ApplyResponse{Response: payload}
+				continue
+			}
+		}
+		responses[i] = &grpc.ApplyResponse{Error: applicationErrorToProto(fsm.ToApplicationError(err))}
+	}
+	return responses
+}
@@ -5,46 +5,169 @@ import (
 	"errors"
 	"github.com/ksrichard/easyraft/grpc"
 	ggrpc "google.golang.org/grpc"
+	"time"
 )
 
+// ApplyOnLeader forwards an already serialized request to the actual Leader Node
 func ApplyOnLeader(node *Node, payload []byte) (interface{}, error) {
-	if node.Raft.Leader() == "" {
-		return nil, errors.New("unknown leader")
+	return ApplyOnLeaderContext(context.Background(), node, payload)
+}
+
+// ApplyOnLeaderContext forwards an already serialized request to the actual Leader Node,
+// cancellation and the deadline of the context are propagated to the leader
+func ApplyOnLeaderContext(ctx context.Context, node *Node, payload []byte) (interface{}, error) {
+	response, err := applyOnLeader(ctx, node, payload)
+	if err != nil {
+		return nil, err
 	}
-	var opt ggrpc.DialOption = ggrpc.EmptyDialOption{}
-	conn, err := ggrpc.Dial(string(node.Raft.Leader()), ggrpc.WithInsecure(), ggrpc.WithBlock(), opt)
+
+	result, err := node.Serializer.Deserialize(response)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
-	client := grpc.NewRaftClient(conn)
 
-	response, err := client.ApplyLog(context.Background(), &grpc.ApplyRequest{Request: payload})
+	return result, nil
+}
+
+// applyOnLeader forwards an already serialized request to the actual Leader Node and returns the serialized response,
+// without a deadline in the context the forward timeout is used
+func applyOnLeader(ctx context.Context, node *Node, payload []byte) ([]byte, error) {
+	client, err := node.leaderClient()
 	if err != nil {
+		node.metrics.forwardError("apply")
 		return nil, err
 	}
 
-	result, err := node.Serializer.Deserialize(response.Response)
+	ctx, cancel := node.forwardContext(ctx)
+	defer cancel()
+	response, err := client.ApplyLog(ctx, &grpc.ApplyRequest{Request: payload})
 	if err != nil {
+		node.metrics.forwardError("apply")
 		return nil, err
 	}
+	if response.Error != nil {
+		return nil, applicationErrorFromProto(response.Error)
+	}
 
-	return result, nil
+	return response.Response, nil
 }
 
-func GetPeerDetails(address string) (*grpc.GetDetailsResponse, error) {
-	var opt ggrpc.DialOption = ggrpc.EmptyDialOption{}
-	conn, err := ggrpc.Dial(address, ggrpc.WithInsecure(), ggrpc.WithBlock(), opt)
+// applyBatchOnLeader forwards already serialized requests to the actual Leader Node with a single call
+// and returns the serialized results in the same order
+func applyBatchOnLeader(ctx context.Context, node *Node, payloads [][]byte) ([]*grpc.ApplyResponse, error) {
+	client, err := node.leaderClient()
+	if err != nil {
+		node.metrics.forwardError("apply_batch")
+		return nil, err
+	}
+
+	ctx, cancel := node.forwardContext(ctx)
+	defer cancel()
+	response, err := client.ApplyBatch(ctx, &grpc.ApplyBatchRequest{Requests: payloads})
+	if err != nil {
+		node.metrics.forwardError("apply_batch")
+		return nil, err
+	}
+
+	return response.Results, nil
+}
+
+// queryOnLeader forwards an already serialized read-only request to the actual Leader Node
+// and returns the serialized response with the applied index of the leader
+func queryOnLeader(node *Node, payload []byte, consistency ReadConsistency, timeout time.Duration) ([]byte, uint64, error) {
+	client, err := node.leaderClient()
+	if err != nil {
+		node.metrics.forwardError("query")
+		return nil, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	response, err := client.Query(ctx, &grpc.QueryRequest{
+		Request:     payload,
+		Consistency: int32(consistency),
+	})
+	if err != nil {
+		node.metrics.forwardError("query")
+		return nil, 0, err
+	}
+	if response.Error != nil {
+		return nil, 0, applicationErrorFromProto(response.Error)
+	}
+
+	return response.Response, response.Index, nil
+}
+
+// defaultPeerDetailsTimeout is how long GetPeerDetails waits for the peer
+const defaultPeerDetailsTimeout = 10 * time.Second
+
+// GetPeerDetails returns the details of the Node listening on the given address, it waits for the peer
+// to become reachable at most 10 seconds, without dial options (e.g. TLS credentials) an insecure connection is used
+func GetPeerDetails(address string, opts ...ggrpc.DialOption) (*grpc.GetDetailsResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultPeerDetailsTimeout)
+	defer cancel()
+	return GetPeerDetailsContext(ctx, address, opts...)
+}
+
+// GetPeerDetailsContext returns the details of the Node listening on the given address, it waits for the peer
+// to become reachable until the context is done, without dial options (e.g. TLS credentials) an insecure connection is used
+func GetPeerDetailsContext(ctx context.Context, address string, opts ...ggrpc.DialOption) (*grpc.GetDetailsResponse, error) {
+	if len(opts) == 0 {
+		opts = []ggrpc.DialOption{ggrpc.WithInsecure()}
+	}
+	conn, err := ggrpc.Dial(address, opts...)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 	client := grpc.NewRaftClient(conn)
 
-	response, err := client.GetDetails(context.Background(), &grpc.GetDetailsRequest{})
+	response, err := client.GetDetails(ctx, &grpc.GetDetailsRequest{}, ggrpc.WaitForReady(true))
 	if err != nil {
 		return nil, err
 	}
 
 	return response, nil
 }
+
+// getPeerDetails returns the details of the Node listening on the given address using a pooled connection,
+// it waits for the peer to become reachable until the forward timeout
+func (n *Node) getPeerDetails(address string) (*grpc.GetDetailsResponse, error) {
+	conn, err := n.connPool.get(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.ForwardTimeout)
+	defer cancel()
+	return grpc.NewRaftClient(conn).GetDetails(ctx, &grpc.GetDetailsRequest{}, ggrpc.WaitForReady(true))
+}
+
+// leaderClient returns a client of the actual Leader Node using a pooled connection
+func (n *Node) leaderClient() (grpc.RaftClient, error) {
+	leader := n.Raft.Leader()
+	if leader == "" {
+		return nil, errors.New("unknown leader")
+	}
+	conn, err := n.connPool.get(string(leader))
+	if err != nil {
+		return nil, err
+	}
+	return grpc.NewRaftClient(conn), nil
+}
+
+// forwardContext returns a context for a forwarded call, it has the forward timeout as deadline if it had none
+func (n *Node) forwardContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, n.config.ForwardTimeout)
+}
+
+// forwardTimeout returns the deadline of a forwarded call, the given timeout if it's set or the configured default
+func (n *Node) forwardTimeout(timeout time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return n.config.ForwardTimeout
+}
@@ -2,7 +2,11 @@ package easyraft
 
 import (
 	"context"
+	"errors"
+	"github.com/hashicorp/raft"
+	"github.com/ksrichard/easyraft/fsm"
 	rgrpc "github.com/ksrichard/easyraft/grpc"
+	"time"
 )
 
 func NewClientGrpcService(node *Node) *ClientGrpcServices {
@@ -17,11 +21,15 @@ type ClientGrpcServices struct {
 }
 
 func (s *ClientGrpcServices) ApplyLog(ctx context.Context, request *rgrpc.ApplyRequest) (*rgrpc.ApplyResponse, error) {
-	result := s.Node.Raft.Apply(request.GetRequest(), 0)
-	if result.Error() != nil {
-		return nil, result.Error()
+	result, err := s.Node.applyLocal(ctx, request.GetRequest())
+	if err != nil {
+		var appErr *fsm.ApplicationError
+		if errors.As(err, &appErr) {
+			return &rgrpc.ApplyResponse{Error: applicationErrorToProto(appErr)}, nil
+		}
+		return nil, err
 	}
-	respPayload, err := s.Node.Serializer.Serialize(result.Response())
+	respPayload, err := s.Node.Serializer.Serialize(result)
 	if err != nil {
 		return nil, err
 	}
@@ -32,5 +40,138 @@ func (s *ClientGrpcServices) GetDetails(context.Context, *rgrpc.GetDetailsReques
 	return &rgrpc.GetDetailsResponse{
 		ServerId:      s.Node.ID,
 		DiscoveryPort: int32(s.Node.DiscoveryPort),
+		Role:          string(s.Node.config.Role),
+		LastIndex:     s.Node.Raft.LastIndex(),
+		AppliedIndex:  s.Node.Raft.AppliedIndex(),
+		CommitIndex:   s.Node.commitIndex(),
 	}, nil
 }
+
+func (s *ClientGrpcServices) Query(ctx context.Context, request *rgrpc.QueryRequest) (*rgrpc.QueryResponse, error) {
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	result, index, err := s.Node.localQuery(request.GetRequest(), ReadConsistency(request.GetConsistency()), timeout)
+	if err != nil {
+		var appErr *fsm.ApplicationError
+		if errors.As(err, &appErr) {
+			return &rgrpc.QueryResponse{Error: applicationErrorToProto(appErr)}, nil
+		}
+		return nil, err
+	}
+	respPayload, err := s.Node.Serializer.Serialize(result)
+	if err != nil {
+		return nil, err
+	}
+	return &rgrpc.QueryResponse{Response: respPayload, Index: index}, nil
+}
+
+func (s *ClientGrpcServices) ApplyBatch(ctx context.Context, request *rgrpc.ApplyBatchRequest) (*rgrpc.ApplyBatchResponse, error) {
+	results, err := s.Node.applyLocalBatch(ctx, request.GetRequests())
+	if err != nil {
+		return nil, err
+	}
+	return &rgrpc.ApplyBatchResponse{Results: s.Node.batchResultsToProto(results)}, nil
+}
+
+func (s *ClientGrpcServices) AddPeer(ctx context.Context, request *rgrpc.AddPeerRequest) (*rgrpc.AddPeerResponse, error) {
+	role := Role(request.GetRole())
+	if role == "" {
+		role = RoleVoter
+	}
+	var err error
+	if isForwarded(ctx) {
+		err = s.Node.addPeerLocal(request.GetId(), request.GetAddress(), role)
+	} else {
+		err = s.Node.AddPeer(ctx, request.GetId(), request.GetAddress(), role)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rgrpc.AddPeerResponse{}, nil
+}
+
+func (s *ClientGrpcServices) RemovePeer(ctx context.Context, request *rgrpc.RemovePeerRequest) (*rgrpc.RemovePeerResponse, error) {
+	var err error
+	if isForwarded(ctx) {
+		err = s.Node.removePeerLocal(request.GetId())
+	} else {
+		err = s.Node.RemovePeer(ctx, request.GetId())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rgrpc.RemovePeerResponse{}, nil
+}
+
+func (s *ClientGrpcServices) Demote(ctx context.Context, request *rgrpc.DemoteRequest) (*rgrpc.DemoteResponse, error) {
+	var err error
+	if isForwarded(ctx) {
+		err = s.Node.demoteLocal(request.GetId())
+	} else {
+		err = s.Node.Demote(ctx, request.GetId())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rgrpc.DemoteResponse{}, nil
+}
+
+func (s *ClientGrpcServices) Members(ctx context.Context, _ *rgrpc.MembersRequest) (*rgrpc.MembersResponse, error) {
+	var members []Member
+	var err error
+	if isForwarded(ctx) {
+		members, err = s.Node.localMembers()
+	} else {
+		members, err = s.Node.Members(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rgrpc.MembersResponse{Members: membersToProto(members)}, nil
+}
+
+func (s *ClientGrpcServices) TransferLeadership(ctx context.Context, request *rgrpc.TransferLeadershipRequest) (*rgrpc.TransferLeadershipResponse, error) {
+	err := s.Node.TransferLeadership(request.GetId())
+	if err == raft.ErrNotLeader && !isForwarded(ctx) {
+		err = s.Node.forwardAdmin(ctx, func(ctx context.Context, client rgrpc.RaftClient) error {
+			_, err := client.TransferLeadership(ctx, request)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rgrpc.TransferLeadershipResponse{}, nil
+}
+
+func (s *ClientGrpcServices) Snapshot(context.Context, *rgrpc.SnapshotRequest) (*rgrpc.SnapshotResponse, error) {
+	err := s.Node.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &rgrpc.SnapshotResponse{}, nil
+}
+
+func (s *ClientGrpcServices) Stats(context.Context, *rgrpc.StatsRequest) (*rgrpc.StatsResponse, error) {
+	return &rgrpc.StatsResponse{Stats: s.Node.Raft.Stats()}, nil
+}
+
+// applicationErrorToProto converts an application error returned by the FSM to its gRPC message
+func applicationErrorToProto(err *fsm.ApplicationError) *rgrpc.ApplicationError {
+	return &rgrpc.ApplicationError{
+		Code:    err.Code,
+		Message: err.Message,
+		Details: err.Details,
+	}
+}
+
+// applicationErrorFromProto converts the gRPC message of an application error back to a Go error
+func applicationErrorFromProto(err *rgrpc.ApplicationError) *fsm.ApplicationError {
+	return &fsm.ApplicationError{
+		Code:    err.GetCode(),
+		Message: err.GetMessage(),
+		Details: err.GetDetails(),
+	}
+}
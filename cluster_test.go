@@ -0,0 +1,126 @@
+package easyraft
+
+import (
+	"fmt"
+	"github.com/hashicorp/raft"
+	"github.com/ksrichard/easyraft/discovery"
+	"github.com/ksrichard/easyraft/fsm"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+)
+
+// testNodeConfig holds the ports and the data directory of a test Node, so it can be restarted with the same ones
+type testNodeConfig struct {
+	raftPort      int
+	discoveryPort int
+	dataDir       string
+}
+
+func newTestNodeConfig(t *testing.T) testNodeConfig {
+	t.Helper()
+	return testNodeConfig{
+		raftPort:      freePort(t),
+		discoveryPort: freePort(t),
+		dataDir:       t.TempDir(),
+	}
+}
+
+func (c testNodeConfig) raftAddress() string {
+	return fmt.Sprintf("127.0.0.1:%d", c.raftPort)
+}
+
+// options returns the options of a test Node discovering the given peers, the given options are applied last
+func (c testNodeConfig) options(peers []string, opts ...Option) []Option {
+	return append([]Option{
+		WithRaftPort(c.raftPort),
+		WithDiscoveryPort(c.discoveryPort),
+		WithBindAddress("127.0.0.1"),
+		WithDataDir(c.dataDir),
+		WithServices(fsm.NewInMemoryMapService()),
+		WithDiscoveryMethod(discovery.NewStaticDiscovery(peers)),
+		WithMemberlistProfile(LocalProfile),
+		WithLogger(log.New(ioutil.Discard, "", 0)),
+		WithForwardTimeout(5 * time.Second),
+	}, opts...)
+}
+
+// freePort returns a TCP port which is free on the loopback interface
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// startTestNode creates and starts a Node, it's stopped when the test finishes
+func startTestNode(t *testing.T, opts ...Option) *Node {
+	t.Helper()
+	node, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(node.Stop)
+	return node
+}
+
+// startTestCluster starts a cluster of the given size, the first Node becomes the leader and the others join it
+// one by one, the given options are applied to every Node
+func startTestCluster(t *testing.T, size int, opts ...Option) []*Node {
+	t.Helper()
+	configs := make([]testNodeConfig, size)
+	peers := make([]string, size)
+	for i := range configs {
+		configs[i] = newTestNodeConfig(t)
+		peers[i] = configs[i].raftAddress()
+	}
+	var nodes []*Node
+	for i, config := range configs {
+		node := startTestNode(t, config.options(peers, opts...)...)
+		nodes = append(nodes, node)
+		if i == 0 {
+			waitUntil(t, "the first node becomes the leader", node.IsLeader)
+			continue
+		}
+		waitUntil(t, fmt.Sprintf("node %d joins the cluster", i), func() bool {
+			return nodes[0].serverAddress(raft.ServerID(node.ID)) != "" && node.LeaderID() == nodes[0].ID
+		})
+	}
+	return nodes
+}
+
+// leaderOf returns the leader of the given nodes
+func leaderOf(t *testing.T, nodes []*Node) *Node {
+	t.Helper()
+	var leader *Node
+	waitUntil(t, "a leader is elected", func() bool {
+		for _, node := range nodes {
+			if node.IsLeader() {
+				leader = node
+				return true
+			}
+		}
+		return false
+	})
+	return leader
+}
+
+// waitUntil waits until the condition is true, the test fails if it isn't within 15 seconds
+func waitUntil(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(15 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", description)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ksrichard/easyraft"
+	"github.com/ksrichard/easyraft/fsm"
+	"github.com/ksrichard/easyraft/grpc"
+	"github.com/ksrichard/easyraft/serializer"
+	ggrpc "google.golang.org/grpc"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: easyraftctl [flags] <command> [arguments]
+
+Commands:
+  members                    list the members of the cluster with their role, address and last index
+  leader                     show the leader of the cluster
+  snapshot                   take a snapshot on the node
+  transfer [id]              transfer the leadership to the given voter (or to the most up-to-date one)
+  remove <id>                remove the given server from the cluster
+  put <map> <key> <value>    put a key into an InMemoryMapService map
+  get <map> <key>            get a key from an InMemoryMapService map
+  delete <map> <key>         delete a key from an InMemoryMapService map
+  stats                      dump the raft stats of the node
+
+Flags:
+`
+
+// ctl holds the connection to the node given with -addr
+type ctl struct {
+	addr     string
+	timeout  time.Duration
+	dialOpts []ggrpc.DialOption
+	conn     *ggrpc.ClientConn
+	client   grpc.RaftClient
+	ser      serializer.Serializer
+	fsm      fsm.FSM
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:5000", "raft (gRPC) address of a node")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of the command")
+	certFile := flag.String("tls-cert", "", "client certificate file (enables TLS)")
+	keyFile := flag.String("tls-key", "", "client private key file")
+	caFile := flag.String("tls-ca", "", "CA bundle to verify the nodes")
+	serverName := flag.String("tls-server-name", "", "expected server name of the nodes")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dialOpts := []ggrpc.DialOption{ggrpc.WithInsecure()}
+	if *certFile != "" {
+		tlsOpt, err := easyraft.TLSDialOption(&easyraft.TLSConfig{
+			CertFile:   *certFile,
+			KeyFile:    *keyFile,
+			CAFile:     *caFile,
+			ServerName: *serverName,
+		})
+		if err != nil {
+			fail(err)
+		}
+		dialOpts = []ggrpc.DialOption{tlsOpt}
+	}
+
+	c, err := newCtl(*addr, *timeout, dialOpts)
+	if err != nil {
+		fail(err)
+	}
+	defer c.conn.Close()
+
+	if err := c.run(flag.Arg(0), flag.Args()[1:]); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	os.Exit(1)
+}
+
+func newCtl(addr string, timeout time.Duration, dialOpts []ggrpc.DialOption) (*ctl, error) {
+	conn, err := ggrpc.Dial(addr, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	ser := serializer.NewMsgPackSerializer()
+	mapFsm := fsm.NewRoutingFSM([]fsm.FSMService{fsm.NewInMemoryMapService()})
+	mapFsm.Init(ser)
+	return &ctl{
+		addr:     addr,
+		timeout:  timeout,
+		dialOpts: dialOpts,
+		conn:     conn,
+		client:   grpc.NewRaftClient(conn),
+		ser:      ser,
+		fsm:      mapFsm,
+	}, nil
+}
+
+// run executes the given command
+func (c *ctl) run(command string, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	switch command {
+	case "members":
+		return c.members(ctx)
+	case "leader":
+		return c.leader(ctx)
+	case "snapshot":
+		if _, err := c.client.Snapshot(ctx, &grpc.SnapshotRequest{}); err != nil {
+			return err
+		}
+		fmt.Println("Snapshot taken")
+		return nil
+	case "transfer":
+		id := ""
+		if len(args) > 0 {
+			id = args[0]
+		}
+		if _, err := c.client.TransferLeadership(ctx, &grpc.TransferLeadershipRequest{Id: id}); err != nil {
+			return err
+		}
+		fmt.Println("Leadership transferred")
+		return c.leader(ctx)
+	case "remove":
+		if len(args) != 1 {
+			return errors.New("usage: remove <id>")
+		}
+		if _, err := c.client.RemovePeer(ctx, &grpc.RemovePeerRequest{Id: args[0]}); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s\n", args[0])
+		return nil
+	case "put":
+		if len(args) != 3 {
+			return errors.New("usage: put <map> <key> <value>")
+		}
+		_, err := c.apply(ctx, fsm.MapPutRequest{MapName: args[0], Key: args[1], Value: args[2]})
+		return err
+	case "get":
+		if len(args) != 2 {
+			return errors.New("usage: get <map> <key>")
+		}
+		value, err := c.query(ctx, fsm.MapGetRequest{MapName: args[0], Key: args[1]})
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			return fmt.Errorf("key %s not found in map %s", args[1], args[0])
+		}
+		fmt.Println(value)
+		return nil
+	case "delete":
+		if len(args) != 2 {
+			return errors.New("usage: delete <map> <key>")
+		}
+		_, err := c.apply(ctx, fsm.MapRemoveRequest{MapName: args[0], Key: args[1]})
+		return err
+	case "stats":
+		return c.stats(ctx)
+	default:
+		return fmt.Errorf("unknown command: %s", command)
+	}
+}
+
+// members prints the members of the cluster, the details are fetched from every member directly
+func (c *ctl) members(ctx context.Context) error {
+	response, err := c.client.Members(ctx, &grpc.MembersRequest{})
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tADDRESS\tSUFFRAGE\tROLE\tLEADER\tLAST INDEX")
+	for _, member := range response.Members {
+		role, lastIndex := "unknown", "unknown"
+		details, err := c.peerDetails(ctx, member.Address)
+		if err == nil {
+			role = details.Role
+			lastIndex = fmt.Sprint(details.LastIndex)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n", member.Id, member.Address, member.Suffrage, role, member.Leader, lastIndex)
+	}
+	return w.Flush()
+}
+
+// leader prints the ID and the address of the leader
+func (c *ctl) leader(ctx context.Context) error {
+	leader, err := c.findLeader(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\t%s\n", leader.Id, leader.Address)
+	return nil
+}
+
+// stats prints the raft stats of the node sorted by name
+func (c *ctl) stats(ctx context.Context) error {
+	response, err := c.client.Stats(ctx, &grpc.StatsRequest{})
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for key := range response.Stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\n", key, response.Stats[key])
+	}
+	return w.Flush()
+}
+
+// apply sends the command to the leader and returns its response
+func (c *ctl) apply(ctx context.Context, request interface{}) (interface{}, error) {
+	payload, err := c.fsm.EncodeCommand(request)
+	if err != nil {
+		return nil, err
+	}
+	var response *grpc.ApplyResponse
+	err = c.onLeader(ctx, func(client grpc.RaftClient) error {
+		response, err = client.ApplyLog(ctx, &grpc.ApplyRequest{Request: payload})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, applicationError(response.Error)
+	}
+	return c.ser.Deserialize(response.Response)
+}
+
+// query sends the read-only request to the leader with linearizable consistency and returns its response
+func (c *ctl) query(ctx context.Context, request interface{}) (interface{}, error) {
+	payload, err := c.fsm.EncodeQuery(request)
+	if err != nil {
+		return nil, err
+	}
+	var response *grpc.QueryResponse
+	err = c.onLeader(ctx, func(client grpc.RaftClient) error {
+		response, err = client.Query(ctx, &grpc.QueryRequest{
+			Request:     payload,
+			Consistency: int32(easyraft.ReadLinearizable),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, applicationError(response.Error)
+	}
+	return c.ser.Deserialize(response.Response)
+}
+
+// onLeader calls the given function with a client connected to the leader
+func (c *ctl) onLeader(ctx context.Context, call func(client grpc.RaftClient) error) error {
+	leader, err := c.findLeader(ctx)
+	if err != nil {
+		return err
+	}
+	if leader.Address == c.addr {
+		return call(c.client)
+	}
+	conn, err := ggrpc.Dial(leader.Address, c.dialOpts...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return call(grpc.NewRaftClient(conn))
+}
+
+// findLeader returns the leader member of the cluster
+func (c *ctl) findLeader(ctx context.Context) (*grpc.Member, error) {
+	response, err := c.client.Members(ctx, &grpc.MembersRequest{})
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range response.Members {
+		if member.Leader {
+			return member, nil
+		}
+	}
+	return nil, errors.New("the cluster has no leader")
+}
+
+// peerDetails returns the details of the node listening on the given address
+func (c *ctl) peerDetails(ctx context.Context, address string) (*grpc.GetDetailsResponse, error) {
+	if address == c.addr {
+		return c.client.GetDetails(ctx, &grpc.GetDetailsRequest{})
+	}
+	conn, err := ggrpc.Dial(address, c.dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return grpc.NewRaftClient(conn).GetDetails(ctx, &grpc.GetDetailsRequest{})
+}
+
+// applicationError converts the application error returned by a node
+func applicationError(appErr *grpc.ApplicationError) error {
+	return &fsm.ApplicationError{
+		Code:    appErr.GetCode(),
+		Message: appErr.GetMessage(),
+		Details: appErr.GetDetails(),
+	}
+}
@@ -0,0 +1,107 @@
+package easyraft
+
+import (
+	"context"
+	"github.com/hashicorp/raft"
+	"sync"
+	"time"
+)
+
+// coalescedApply is a request waiting in the coalescer to be appended to the raft log
+type coalescedApply struct {
+	ctx     context.Context
+	payload []byte
+
+	// future and err are set by the coalescer before enqueued is closed
+	future   raft.ApplyFuture
+	err      error
+	enqueued chan struct{}
+}
+
+// coalescer collects concurrently applied requests for a short window (or until maxEntries requests arrive)
+// and appends them to the raft log together, so they are replicated and committed as a single batch
+type coalescer struct {
+	raft       *raft.Raft
+	window     time.Duration
+	maxEntries int
+	requests   chan *coalescedApply
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+}
+
+func newCoalescer(r *raft.Raft, window time.Duration, maxEntries int) *coalescer {
+	return &coalescer{
+		raft:       r,
+		window:     window,
+		maxEntries: maxEntries,
+		requests:   make(chan *coalescedApply),
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// apply hands over the payload to the coalescer and returns the raft future of its log once it has been enqueued
+func (c *coalescer) apply(ctx context.Context, payload []byte) (raft.ApplyFuture, error) {
+	request := &coalescedApply{
+		ctx:      ctx,
+		payload:  payload,
+		enqueued: make(chan struct{}),
+	}
+	select {
+	case c.requests <- request:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.stopCh:
+		return nil, raft.ErrRaftShutdown
+	}
+	<-request.enqueued
+	return request.future, request.err
+}
+
+// run collects and flushes the requests until the coalescer is stopped
+func (c *coalescer) run() {
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case request := <-c.requests:
+			batch := []*coalescedApply{request}
+			timer := time.NewTimer(c.window)
+		collect:
+			for len(batch) < c.maxEntries {
+				select {
+				case request := <-c.requests:
+					batch = append(batch, request)
+				case <-timer.C:
+					break collect
+				case <-c.stopCh:
+					break collect
+				}
+			}
+			timer.Stop()
+			c.flush(batch)
+		}
+	}
+}
+
+// flush appends the collected requests to the raft log in the order they arrived
+func (c *coalescer) flush(batch []*coalescedApply) {
+	for _, request := range batch {
+		timeout, err := enqueueTimeout(request.ctx)
+		if err == nil {
+			err = request.ctx.Err()
+		}
+		if err != nil {
+			request.err = err
+		} else {
+			request.future = c.raft.ApplyLog(raft.Log{Data: request.payload}, timeout)
+		}
+		close(request.enqueued)
+	}
+}
+
+// stop stops collecting requests, the already collected ones are still flushed
+func (c *coalescer) stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+	})
+}
@@ -0,0 +1,209 @@
+package easyraft
+
+import (
+	"context"
+	"github.com/hashicorp/raft"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingFSM records the data of the applied logs in order
+type recordingFSM struct {
+	sync.Mutex
+	applied []string
+}
+
+func (f *recordingFSM) Apply(log *raft.Log) interface{} {
+	f.Lock()
+	defer f.Unlock()
+	f.applied = append(f.applied, string(log.Data))
+	return nil
+}
+
+func (f *recordingFSM) Snapshot() (raft.FSMSnapshot, error) {
+	return nil, raft.ErrNothingNewToSnapshot
+}
+
+func (f *recordingFSM) Restore(closer io.ReadCloser) error {
+	return closer.Close()
+}
+
+func (f *recordingFSM) appliedLogs() []string {
+	f.Lock()
+	defer f.Unlock()
+	return append([]string(nil), f.applied...)
+}
+
+// newTestRaft starts a single node in-memory raft cluster and waits until it becomes the leader
+func newTestRaft(t *testing.T) (*raft.Raft, *recordingFSM) {
+	t.Helper()
+	conf := raft.DefaultConfig()
+	conf.LocalID = "test"
+	conf.HeartbeatTimeout = 50 * time.Millisecond
+	conf.ElectionTimeout = 50 * time.Millisecond
+	conf.LeaderLeaseTimeout = 50 * time.Millisecond
+	conf.CommitTimeout = 5 * time.Millisecond
+	conf.LogOutput = ioutil.Discard
+	store := raft.NewInmemStore()
+	address, transport := raft.NewInmemTransport("")
+	sm := &recordingFSM{}
+	r, err := raft.NewRaft(conf, sm, store, store, raft.NewInmemSnapshotStore(), transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = r.Shutdown().Error()
+	})
+	err = r.BootstrapCluster(raft.Configuration{
+		Servers: []raft.Server{{ID: conf.LocalID, Address: address}},
+	}).Error()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-r.LeaderCh():
+	case <-time.After(5 * time.Second):
+		t.Fatal("raft didn't become the leader")
+	}
+	return r, sm
+}
+
+func newCoalescedApply(ctx context.Context, payload string) *coalescedApply {
+	return &coalescedApply{ctx: ctx, payload: []byte(payload), enqueued: make(chan struct{})}
+}
+
+func TestCoalescerFlush(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	tests := []struct {
+		name        string
+		contexts    []context.Context
+		wantErrs    []error
+		wantApplied []string
+	}{
+		{
+			name:        "applied in arrival order",
+			contexts:    []context.Context{context.Background(), context.Background(), context.Background()},
+			wantErrs:    []error{nil, nil, nil},
+			wantApplied: []string{"0", "1", "2"},
+		},
+		{
+			name:        "canceled request skipped",
+			contexts:    []context.Context{context.Background(), canceled, context.Background()},
+			wantErrs:    []error{nil, context.Canceled, nil},
+			wantApplied: []string{"0", "2"},
+		},
+		{
+			name:        "expired request skipped",
+			contexts:    []context.Context{expired, context.Background()},
+			wantErrs:    []error{context.DeadlineExceeded, nil},
+			wantApplied: []string{"1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, sm := newTestRaft(t)
+			c := newCoalescer(r, time.Hour, len(tt.contexts))
+			var batch []*coalescedApply
+			for i, ctx := range tt.contexts {
+				batch = append(batch, newCoalescedApply(ctx, string(rune('0'+i))))
+			}
+			c.flush(batch)
+
+			var lastIndex uint64
+			for i, request := range batch {
+				select {
+				case <-request.enqueued:
+				default:
+					t.Fatalf("request %d not enqueued by flush", i)
+				}
+				if request.err != tt.wantErrs[i] {
+					t.Fatalf("request %d error = %v, want %v", i, request.err, tt.wantErrs[i])
+				}
+				if request.err != nil {
+					continue
+				}
+				if err := request.future.Error(); err != nil {
+					t.Fatalf("request %d failed: %v", i, err)
+				}
+				if request.future.Index() <= lastIndex {
+					t.Fatalf("request %d index %d is not after %d", i, request.future.Index(), lastIndex)
+				}
+				lastIndex = request.future.Index()
+			}
+			if applied := sm.appliedLogs(); !reflect.DeepEqual(applied, tt.wantApplied) {
+				t.Errorf("applied = %v, want %v", applied, tt.wantApplied)
+			}
+		})
+	}
+}
+
+func TestCoalescerRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int
+		requests   int
+		// stop stops the coalescer after the requests have been collected
+		stop bool
+	}{
+		{name: "flushed when max entries reached", maxEntries: 3, requests: 3},
+		{name: "collected requests flushed on stop", maxEntries: 10, requests: 2, stop: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, sm := newTestRaft(t)
+			// the window never expires during the test, only max entries or stop flush the batch
+			c := newCoalescer(r, time.Hour, tt.maxEntries)
+			go c.run()
+			defer c.stop()
+
+			var batch []*coalescedApply
+			var want []string
+			for i := 0; i < tt.requests; i++ {
+				request := newCoalescedApply(context.Background(), string(rune('0'+i)))
+				// the send returns once run has collected the request
+				c.requests <- request
+				batch = append(batch, request)
+				want = append(want, string(request.payload))
+			}
+			if tt.stop {
+				c.stop()
+			}
+			for i, request := range batch {
+				select {
+				case <-request.enqueued:
+				case <-time.After(5 * time.Second):
+					t.Fatalf("request %d not flushed", i)
+				}
+				if request.err != nil {
+					t.Fatalf("request %d error = %v", i, request.err)
+				}
+				if err := request.future.Error(); err != nil {
+					t.Fatalf("request %d failed: %v", i, err)
+				}
+			}
+			if applied := sm.appliedLogs(); !reflect.DeepEqual(applied, want) {
+				t.Errorf("applied = %v, want %v", applied, want)
+			}
+		})
+	}
+}
+
+func TestCoalescerApplyAfterStop(t *testing.T) {
+	r, _ := newTestRaft(t)
+	c := newCoalescer(r, time.Millisecond, 10)
+	go c.run()
+	c.stop()
+	c.stop()
+
+	if _, err := c.apply(context.Background(), []byte("0")); err != raft.ErrRaftShutdown {
+		t.Fatalf("apply error = %v, want %v", err, raft.ErrRaftShutdown)
+	}
+}
@@ -0,0 +1,242 @@
+package easyraft
+
+import (
+	"errors"
+	"github.com/hashicorp/memberlist"
+	"github.com/ksrichard/easyraft/discovery"
+	"github.com/ksrichard/easyraft/fsm"
+	"github.com/ksrichard/easyraft/serializer"
+	ggrpc "google.golang.org/grpc"
+	"log"
+	"time"
+)
+
+// MemberlistProfile selects the memberlist default configuration used for discovery
+type MemberlistProfile string
+
+const (
+	// LANProfile is tuned for nodes in the same local network
+	LANProfile MemberlistProfile = "lan"
+
+	// WANProfile is tuned for nodes communicating over the internet, or with higher latencies (default)
+	WANProfile MemberlistProfile = "wan"
+
+	// LocalProfile is tuned for nodes running on the same host (loopback)
+	LocalProfile MemberlistProfile = "local"
+)
+
+const (
+	defaultBindAddress    = "0.0.0.0"
+	defaultLogCacheSize   = 512
+	defaultLogLevel       = "Info"
+	defaultSnapshotRetain = 2
+	defaultQueryTimeout   = 5 * time.Second
+	defaultForwardTimeout = 10 * time.Second
+	defaultReadyMaxLag    = 100
+)
+
+// Config holds all the settings needed to create an EasyRaft Node
+type Config struct {
+	// NodeID is the unique ID of the Node in the cluster (default: random ID, which is persisted in durable mode)
+	NodeID string
+
+	// RaftPort is the port where the gRPC (raft + client services) server listens
+	RaftPort int
+
+	// DiscoveryPort is the port used by memberlist based discovery
+	DiscoveryPort int
+
+	// BindAddress is the address where the gRPC server and the discovery listens (default: 0.0.0.0)
+	BindAddress string
+
+	// AdvertiseAddress is the address other nodes use to reach this Node (default: BindAddress)
+	AdvertiseAddress string
+
+	// DataDir is the directory where the raft log, stable store and snapshots are stored
+	DataDir string
+
+	// Role is the role of the Node in the raft cluster (default: RoleVoter), nonvoter and witness nodes
+	// never bootstrap a cluster, they wait until the leader adds them
+	Role Role
+
+	// MaxVoters is the maximum number of voters in the cluster, further voter-eligible nodes join as nonvoters
+	// and are promoted by the leader when voters are removed (default: 0, every voter-eligible Node is a voter)
+	MaxVoters int
+
+	// Autopilot enables tracking the health of the servers on the leader and optionally removing the dead ones,
+	// regardless of the discovery method (default: disabled)
+	Autopilot *AutopilotConfig
+
+	// Services are the FSM services the routing state machine will route requests to
+	Services []fsm.FSMService
+
+	// Serializer is used to serialize/deserialize raft log payloads (default: MsgPack)
+	Serializer serializer.Serializer
+
+	// DiscoveryMethod is used to discover other nodes automatically
+	DiscoveryMethod discovery.DiscoveryMethod
+
+	// SnapshotEnabled enables raft snapshots stored on disk under DataDir
+	SnapshotEnabled bool
+
+	// SnapshotRetain is the number of snapshots kept on disk (default: 2)
+	SnapshotRetain int
+
+	// SnapshotThreshold is the number of outstanding logs which triggers a snapshot (default: raft default)
+	SnapshotThreshold uint64
+
+	// SnapshotInterval is how often raft checks whether a snapshot should be taken (default: raft default)
+	SnapshotInterval time.Duration
+
+	// Durable keeps the node ID, the raft log and the stable store in DataDir between restarts,
+	// so a restarted node rejoins the cluster as the same member and replays its log.
+	// When it's false, the stored state is wiped on every start and the node gets a brand-new ID.
+	Durable bool
+
+	// HeartbeatTimeout is the raft follower heartbeat timeout (default: raft default)
+	HeartbeatTimeout time.Duration
+
+	// ElectionTimeout is the raft candidate election timeout (default: raft default)
+	ElectionTimeout time.Duration
+
+	// CommitTimeout is the raft commit timeout (default: raft default)
+	CommitTimeout time.Duration
+
+	// LeaderLeaseTimeout is the raft leader lease timeout (default: raft default)
+	LeaderLeaseTimeout time.Duration
+
+	// MaxAppendEntries is the maximum number of logs appended (and applied) at once (default: raft default)
+	MaxAppendEntries int
+
+	// BatchApply buffers the raft apply queue up to MaxAppendEntries, so logs applied concurrently are committed
+	// together (default: false). The raft enqueue timeout is not guaranteed anymore, but callers still stop waiting
+	// at their deadline. RaftApplyBatch enqueues all its logs before waiting, so it doesn't need it.
+	BatchApply bool
+
+	// CoalesceWindow enables write coalescing on the leader when it's set: concurrent RaftApply calls
+	// (including the ones forwarded by other nodes) are collected for this long, or until CoalesceMaxEntries
+	// requests arrive, and are committed together. Every caller still gets its own result. It implies BatchApply.
+	CoalesceWindow time.Duration
+
+	// CoalesceMaxEntries is the maximum number of requests committed together by write coalescing,
+	// MaxAppendEntries is raised to it when it's lower (default: MaxAppendEntries)
+	CoalesceMaxEntries int
+
+	// LogCacheSize is the number of raft logs cached in memory (default: 512)
+	LogCacheSize int
+
+	// LogLevel is the log level of raft (default: Info)
+	LogLevel string
+
+	// MemberlistProfile is the memberlist configuration profile used for discovery (default: WANProfile)
+	MemberlistProfile MemberlistProfile
+
+	// QueryTimeout is the maximum time a Query waits for the state machine to catch up (default: 5s)
+	QueryTimeout time.Duration
+
+	// ForwardTimeout is the deadline of the calls forwarded to other nodes when the caller has no timeout (default: 10s)
+	ForwardTimeout time.Duration
+
+	// Metrics enables the Prometheus metrics of the Node when set (default: disabled)
+	Metrics *MetricsConfig
+
+	// ReadyMaxLag is the maximum number of logs committed by the leader, which are not applied yet
+	// by a Ready Node (default: 100)
+	ReadyMaxLag uint64
+
+	// TLS enables mutual TLS for all the gRPC communication (raft, forwarding, peer details) when set
+	TLS *TLSConfig
+
+	// GossipKeys enables encryption and authentication of the discovery gossip, the first key is the primary one
+	// used for encryption, all of them are used for decryption. Keys must be 16, 24 or 32 bytes long (AES-128/192/256).
+	GossipKeys [][]byte
+
+	// JoinAllowlist contains IP addresses and CIDR ranges of nodes allowed to join the raft cluster
+	// (default: every discovered Node is allowed)
+	JoinAllowlist []string
+
+	// HTTPAddress enables the HTTP admin and status API (/v1/status, /v1/members, /v1/health and /v1/snapshot)
+	// listening on the given address, e.g. ":8080" (default: disabled). With TLS it's served over mutual TLS,
+	// otherwise it's unauthenticated, so bind it to localhost or a private interface.
+	HTTPAddress string
+
+	// GrpcServerOptions are passed to the gRPC server of the Node
+	GrpcServerOptions []ggrpc.ServerOption
+
+	// Logger is the logger of the Node (default: log.Default() with "[EasyRaft] " prefix)
+	Logger *log.Logger
+}
+
+// DefaultConfig returns a Config with all the defaults set, the ports, the data directory,
+// the services and the discovery method still need to be set
+func DefaultConfig() *Config {
+	config := &Config{}
+	config.setDefaults()
+	return config
+}
+
+// setDefaults fills all the unset optional settings with their default values
+func (c *Config) setDefaults() {
+	if c.BindAddress == "" {
+		c.BindAddress = defaultBindAddress
+	}
+	if c.Role == "" {
+		c.Role = RoleVoter
+	}
+	if c.Serializer == nil {
+		c.Serializer = serializer.NewMsgPackSerializer()
+	}
+	if c.SnapshotRetain <= 0 {
+		c.SnapshotRetain = defaultSnapshotRetain
+	}
+	if c.LogCacheSize <= 0 {
+		c.LogCacheSize = defaultLogCacheSize
+	}
+	if c.QueryTimeout <= 0 {
+		c.QueryTimeout = defaultQueryTimeout
+	}
+	if c.ForwardTimeout <= 0 {
+		c.ForwardTimeout = defaultForwardTimeout
+	}
+	if c.ReadyMaxLag == 0 {
+		c.ReadyMaxLag = defaultReadyMaxLag
+	}
+	if c.LogLevel == "" {
+		c.LogLevel = defaultLogLevel
+	}
+	if c.MemberlistProfile == "" {
+		c.MemberlistProfile = WANProfile
+	}
+	if c.Logger == nil {
+		c.Logger = log.Default()
+		c.Logger.SetPrefix("[EasyRaft] ")
+	}
+}
+
+// validate checks that all the mandatory settings are set
+func (c *Config) validate() error {
+	if c.RaftPort <= 0 {
+		return errors.New("raft port must be set")
+	}
+	if c.DataDir == "" {
+		return errors.New("data directory must be set")
+	}
+	if c.DiscoveryMethod == nil {
+		return errors.New("discovery method must be set")
+	}
+	return c.Role.validate()
+}
+
+// memberlistConfig returns the memberlist default configuration of the selected profile
+func (c *Config) memberlistConfig() (*memberlist.Config, error) {
+	switch c.MemberlistProfile {
+	case LANProfile:
+		return memberlist.DefaultLANConfig(), nil
+	case WANProfile:
+		return memberlist.DefaultWANConfig(), nil
+	case LocalProfile:
+		return memberlist.DefaultLocalConfig(), nil
+	default:
+		return nil, errors.New("unknown memberlist profile: " + string(c.MemberlistProfile))
+	}
+}
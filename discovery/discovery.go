@@ -1,5 +1,7 @@
 package discovery
 
+import "log"
+
 // DiscoveryMethod gives the interface to perform automatic Node discovery
 type DiscoveryMethod interface {
 	// Start is about to start the discovery method
@@ -12,3 +14,9 @@ type DiscoveryMethod interface {
 	// Stop should stop the discovery method and all of its goroutines, it should close discovery channel returned in Start
 	Stop()
 }
+
+// LoggerSetter is an optional extension of DiscoveryMethod for methods which log, the Node passes its logger
+// before starting the discovery (default: the standard logger)
+type LoggerSetter interface {
+	SetLogger(logger *log.Logger)
+}
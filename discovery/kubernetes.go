@@ -26,6 +26,7 @@ type KubernetesDiscovery struct {
 	discoveryChan         chan string
 	stopChan              chan bool
 	delayTime             time.Duration
+	logger                *log.Logger
 }
 
 func NewKubernetesDiscovery(namespace string, serviceLabels map[string]string, raftPortName string) DiscoveryMethod {
@@ -44,9 +45,15 @@ func NewKubernetesDiscovery(namespace string, serviceLabels map[string]string, r
 		discoveryChan:         make(chan string),
 		stopChan:              make(chan bool),
 		delayTime:             delayTime,
+		logger:                log.Default(),
 	}
 }
 
+// SetLogger sets the logger of the Kubernetes API errors
+func (k *KubernetesDiscovery) SetLogger(logger *log.Logger) {
+	k.logger = logger
+}
+
 func (k *KubernetesDiscovery) Start(_ string, _ int) (chan string, error) {
 	config, err := rest.InClusterConfig()
 	if err != nil {
@@ -71,7 +78,7 @@ func (k *KubernetesDiscovery) discovery(clientSet *kubernetes.Clientset) {
 				Watch:         false,
 			})
 			if err != nil {
-				log.Println(err)
+				k.logger.Printf("Failed to list Kubernetes services: %q\n", err.Error())
 				continue
 			}
 
@@ -82,7 +89,7 @@ func (k *KubernetesDiscovery) discovery(clientSet *kubernetes.Clientset) {
 				}
 				pods, err := clientSet.CoreV1().Pods(svc.Namespace).List(context.Background(), listOptions)
 				if err != nil {
-					log.Println(err)
+					k.logger.Printf("Failed to list Kubernetes pods of service %s: %q\n", svc.Name, err.Error())
 					continue
 				}
 				for _, pod := range pods.Items {
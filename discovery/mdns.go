@@ -20,6 +20,7 @@ type MDNSDiscovery struct {
 	mdnsServer    *zeroconf.Server
 	discoveryChan chan string
 	stopChan      chan bool
+	logger        *log.Logger
 }
 
 func NewMDNSDiscovery() DiscoveryMethod {
@@ -29,31 +30,39 @@ func NewMDNSDiscovery() DiscoveryMethod {
 		delayTime:     delayTime,
 		discoveryChan: make(chan string),
 		stopChan:      make(chan bool),
+		logger:        log.Default(),
 	}
 }
 
+// SetLogger sets the logger of the mDNS lookup errors
+func (d *MDNSDiscovery) SetLogger(logger *log.Logger) {
+	d.logger = logger
+}
+
 func (d *MDNSDiscovery) Start(nodeID string, nodePort int) (chan string, error) {
 	d.nodeID, d.nodePort = nodeID, nodePort
 	if d.discoveryChan == nil {
 		d.discoveryChan = make(chan string)
 	}
-	go d.discovery()
-	return d.discoveryChan, nil
-}
 
-func (d *MDNSDiscovery) discovery() {
 	// expose mdns server
 	mdnsServer, err := d.exposeMDNS()
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("failed to expose mDNS service: %w", err)
 	}
-	d.mdnsServer = mdnsServer
 
 	// fetch mDNS enabled raft nodes
 	resolver, err := zeroconf.NewResolver(nil)
 	if err != nil {
-		log.Fatalln("Failed to initialize mDNS resolver:", err.Error())
+		mdnsServer.Shutdown()
+		return nil, fmt.Errorf("failed to initialize mDNS resolver: %w", err)
 	}
+	d.mdnsServer = mdnsServer
+	go d.discovery(resolver)
+	return d.discoveryChan, nil
+}
+
+func (d *MDNSDiscovery) discovery(resolver *zeroconf.Resolver) {
 	entries := make(chan *zeroconf.ServiceEntry)
 	go func() {
 		for {
@@ -72,9 +81,9 @@ func (d *MDNSDiscovery) discovery() {
 			cancel()
 			break
 		default:
-			err = resolver.Browse(ctx, mdnsServiceName, "local.", entries)
+			err := resolver.Browse(ctx, mdnsServiceName, "local.", entries)
 			if err != nil {
-				log.Printf("Error during mDNS lookup: %v\n", err)
+				d.logger.Printf("Error during mDNS lookup: %v\n", err)
 			}
 			time.Sleep(d.delayTime)
 		}
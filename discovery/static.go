@@ -20,14 +20,19 @@ func (d *StaticDiscovery) SupportsNodeAutoRemoval() bool {
 
 func (d *StaticDiscovery) Start(_ string, _ int) (chan string, error) {
 	go func() {
+		defer close(d.discoveryChan)
 		for _, peer := range d.Peers {
-			d.discoveryChan <- peer
+			select {
+			case d.discoveryChan <- peer:
+			case <-d.stopChan:
+				return
+			}
 		}
+		<-d.stopChan
 	}()
 	return d.discoveryChan, nil
 }
 
 func (d *StaticDiscovery) Stop() {
-	close(d.discoveryChan)
-	d.stopChan <- true
+	close(d.stopChan)
 }
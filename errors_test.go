@@ -0,0 +1,108 @@
+package easyraft
+
+import (
+	"errors"
+	"github.com/ksrichard/easyraft/fsm"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type withdrawRequest struct {
+	Amount int
+}
+
+type failingRequest struct{}
+
+type balanceQuery struct{}
+
+var errInsufficientFunds = fsm.NewApplicationError("insufficient_funds", "")
+
+// newFailingService returns a service whose handlers only return errors, so it can be shared by the test nodes
+func newFailingService() *fsm.TypedService {
+	svc := fsm.NewTypedService("accounts")
+	fsm.RegisterHandler(svc, func(req withdrawRequest) (int, error) {
+		return 0, fsm.NewApplicationError("insufficient_funds", "balance too low").WithDetail("balance", "10")
+	})
+	fsm.RegisterHandler(svc, func(req failingRequest) (int, error) {
+		return 0, errors.New("something went wrong")
+	})
+	fsm.RegisterQueryHandler(svc, func(req balanceQuery) (int, error) {
+		return 0, fsm.NewApplicationError("account_locked", "account is locked").WithDetail("reason", "audit")
+	})
+	return svc
+}
+
+func TestApplicationErrorForwarding(t *testing.T) {
+	nodes := startTestCluster(t, 2, WithServices(newFailingService()))
+	leader := leaderOf(t, nodes)
+	follower := nodes[0]
+	if follower == leader {
+		follower = nodes[1]
+	}
+
+	operations := []struct {
+		name    string
+		call    func(node *Node) error
+		wantErr *fsm.ApplicationError
+	}{
+		{
+			name: "apply",
+			call: func(node *Node) error {
+				_, err := node.RaftApply(withdrawRequest{Amount: 100}, time.Second)
+				return err
+			},
+			wantErr: &fsm.ApplicationError{Code: "insufficient_funds", Message: "balance too low", Details: map[string]string{"balance": "10"}},
+		},
+		{
+			name: "apply batch",
+			call: func(node *Node) error {
+				results, err := node.RaftApplyBatch([]interface{}{withdrawRequest{Amount: 100}}, time.Second)
+				if err != nil {
+					t.Fatalf("batch failed: %v", err)
+				}
+				return results[0].Error
+			},
+			wantErr: &fsm.ApplicationError{Code: "insufficient_funds", Message: "balance too low", Details: map[string]string{"balance": "10"}},
+		},
+		{
+			name: "apply returning an error without code",
+			call: func(node *Node) error {
+				_, err := node.RaftApply(failingRequest{}, time.Second)
+				return err
+			},
+			wantErr: &fsm.ApplicationError{Code: fsm.UnknownErrorCode, Message: "something went wrong"},
+		},
+		{
+			name: "query",
+			call: func(node *Node) error {
+				_, _, err := node.Query(balanceQuery{}, ReadLease)
+				return err
+			},
+			wantErr: &fsm.ApplicationError{Code: "account_locked", Message: "account is locked", Details: map[string]string{"reason": "audit"}},
+		},
+	}
+	for _, operation := range operations {
+		for _, target := range []struct {
+			name string
+			node *Node
+		}{{name: "on the leader", node: leader}, {name: "forwarded by a follower", node: follower}} {
+			t.Run(operation.name+" "+target.name, func(t *testing.T) {
+				err := operation.call(target.node)
+				var appErr *fsm.ApplicationError
+				if !errors.As(err, &appErr) {
+					t.Fatalf("error = %#v, want an fsm.ApplicationError", err)
+				}
+				if appErr.Code != operation.wantErr.Code || appErr.Message != operation.wantErr.Message {
+					t.Errorf("error = %s: %s, want %s: %s", appErr.Code, appErr.Message, operation.wantErr.Code, operation.wantErr.Message)
+				}
+				if len(appErr.Details)+len(operation.wantErr.Details) > 0 && !reflect.DeepEqual(appErr.Details, operation.wantErr.Details) {
+					t.Errorf("details = %v, want %v", appErr.Details, operation.wantErr.Details)
+				}
+				if got, want := errors.Is(err, errInsufficientFunds), operation.wantErr.Code == errInsufficientFunds.Code; got != want {
+					t.Errorf("errors.Is(err, errInsufficientFunds) = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package easyraft
+
+import (
+	"github.com/hashicorp/raft"
+	"sync"
+	"time"
+)
+
+// EventType is the type of a cluster Event
+type EventType int
+
+const (
+	// PeerDiscovered is sent when a peer is found by the discovery
+	PeerDiscovered EventType = iota
+
+	// PeerJoinedRaft is sent by the leader when it has added a discovered peer to the raft cluster
+	PeerJoinedRaft
+
+	// PeerRemoved is sent when a peer left the cluster or became unavailable
+	PeerRemoved
+
+	// PeerSuspect is sent by the leader when a peer stops responding to heartbeats
+	PeerSuspect
+
+	// LeadershipChanged is sent when the leader of the cluster changes, the event is about the new leader
+	// (the node ID and address are empty when there is no leader)
+	LeadershipChanged
+)
+
+func (t EventType) String() string {
+	switch t {
+	case PeerDiscovered:
+		return "PeerDiscovered"
+	case PeerJoinedRaft:
+		return "PeerJoinedRaft"
+	case PeerRemoved:
+		return "PeerRemoved"
+	case PeerSuspect:
+		return "PeerSuspect"
+	case LeadershipChanged:
+		return "LeadershipChanged"
+	default:
+		return "Unknown"
+	}
+}
+
+// defaultEventBufferSize is the size of the event channels when no buffer size is given
+const defaultEventBufferSize = 64
+
+// Event is a change of the cluster topology observed by this Node
+type Event struct {
+	Type EventType
+
+	// NodeID is the ID of the peer (or the new leader) the event is about
+	NodeID string
+
+	// Address is the raft address of the peer (or the new leader)
+	Address string
+
+	// Suffrage is the raft suffrage of the peer when the event happened ("Voter", "Nonvoter" or "Staging"),
+	// it's empty when the peer is not part of the raft cluster
+	Suffrage string
+
+	Time time.Time
+}
+
+// eventSubscribers holds the channels of the event subscribers
+type eventSubscribers struct {
+	sync.Mutex
+	nextId   uint64
+	channels map[uint64]chan Event
+	closed   bool
+}
+
+// closeAll closes all the subscriptions, new subscriptions are closed right away
+func (s *eventSubscribers) closeAll() {
+	s.Lock()
+	defer s.Unlock()
+	for id, ch := range s.channels {
+		close(ch)
+		delete(s.channels, id)
+	}
+	s.closed = true
+}
+
+// SubscribeEvents returns a channel which receives the cluster events observed by this Node from now on, and a function
+// to cancel the subscription. Events are dropped (and logged) when the buffer of the channel is full,
+// without a positive buffer size the default (64) is used. The channel is closed when the subscription is cancelled
+// or the Node stops.
+func (n *Node) SubscribeEvents(bufferSize int) (<-chan Event, func()) {
+	if bufferSize <= 0 {
+		bufferSize = defaultEventBufferSize
+	}
+	ch := make(chan Event, bufferSize)
+	n.eventSubs.Lock()
+	defer n.eventSubs.Unlock()
+	if n.eventSubs.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if n.eventSubs.channels == nil {
+		n.eventSubs.channels = map[uint64]chan Event{}
+	}
+	id := n.eventSubs.nextId
+	n.eventSubs.nextId++
+	n.eventSubs.channels[id] = ch
+
+	return ch, func() {
+		n.eventSubs.Lock()
+		defer n.eventSubs.Unlock()
+		if _, ok := n.eventSubs.channels[id]; ok {
+			close(ch)
+			delete(n.eventSubs.channels, id)
+		}
+	}
+}
+
+// publishEvent sends an event about the given peer to all the subscribers
+func (n *Node) publishEvent(eventType EventType, nodeId string, address string) {
+	n.metrics.event(eventType)
+	event := Event{
+		Type:     eventType,
+		NodeID:   nodeId,
+		Address:  address,
+		Suffrage: n.serverSuffrage(nodeId),
+		Time:     time.Now(),
+	}
+	n.eventSubs.Lock()
+	defer n.eventSubs.Unlock()
+	for _, ch := range n.eventSubs.channels {
+		select {
+		case ch <- event:
+		default:
+			n.logger.Printf("Event subscriber is too slow, dropping %s event of %s\n", eventType, nodeId)
+		}
+	}
+}
+
+// serverSuffrage returns the raft suffrage of the server, or an empty string when it's not part of the configuration
+func (n *Node) serverSuffrage(nodeId string) string {
+	if nodeId == "" {
+		return ""
+	}
+	for _, server := range n.Raft.GetConfiguration().Configuration().Servers {
+		if server.ID == raft.ServerID(nodeId) {
+			return server.Suffrage.String()
+		}
+	}
+	return ""
+}
+
+// serverAddress returns the raft address of the server, or an empty string when it's not part of the configuration
+func (n *Node) serverAddress(nodeId raft.ServerID) string {
+	for _, server := range n.Raft.GetConfiguration().Configuration().Servers {
+		if server.ID == nodeId {
+			return string(server.Address)
+		}
+	}
+	return ""
+}
+
+// handleFailedHeartbeat is called on the leader every time a heartbeat to a peer fails,
+// the peer is reported as suspect once until its heartbeats resume
+func (n *Node) handleFailedHeartbeat(peerId raft.ServerID) {
+	if _, suspected := n.suspectPeers.LoadOrStore(peerId, true); !suspected {
+		n.publishEvent(PeerSuspect, string(peerId), n.serverAddress(peerId))
+	}
+}
+
+// handleResumedHeartbeat is called on the leader when the heartbeats to a peer resume after failures
+func (n *Node) handleResumedHeartbeat(peerId raft.ServerID) {
+	n.suspectPeers.Delete(peerId)
+}
@@ -6,3 +6,5 @@ require (
 	github.com/gin-gonic/gin v1.7.7
 	github.com/ksrichard/easyraft v1.0.0
 )
+
+replace github.com/ksrichard/easyraft => ../..
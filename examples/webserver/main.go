@@ -62,7 +62,7 @@ func main() {
 	r.GET("/get", func(ctx *gin.Context) {
 		mapName, _ := ctx.GetQuery("map")
 		key, _ := ctx.GetQuery("key")
-		result, err := node.RaftApply(
+		result, err := node.Read(
 			fsm.MapGetRequest{
 				MapName: mapName,
 				Key:     key,
@@ -7,15 +7,20 @@ import (
 	"github.com/ksrichard/easyraft/serializer"
 	"io"
 	"io/ioutil"
-	"log"
-	"strings"
+	"reflect"
+	"time"
 )
 
+// ApplyObserver is notified about every request applied by the RoutingFSM with the name of the target service,
+// the type name of the request, the time it took to apply and whether the service returned an error
+type ApplyObserver func(serviceName string, requestType string, duration time.Duration, failed bool)
+
 type RoutingFSM struct {
-	services            map[string]FSMService
-	ser                 serializer.Serializer
-	reqDataTypes        []interface{}
-	reqServiceDataTypes map[string]FSMService
+	services map[string]FSMService
+	ser      serializer.Serializer
+	commands *typeRegistry
+	queries  *typeRegistry
+	observer ApplyObserver
 }
 
 func NewRoutingFSM(services []FSMService) FSM {
@@ -24,56 +29,125 @@ func NewRoutingFSM(services []FSMService) FSM {
 		servicesMap[service.Name()] = service
 	}
 	return &RoutingFSM{
-		services:            servicesMap,
-		reqDataTypes:        []interface{}{},
-		reqServiceDataTypes: map[string]FSMService{},
+		services: servicesMap,
+		commands: newTypeRegistry(),
+		queries:  newTypeRegistry(),
 	}
 }
 
 func (i *RoutingFSM) Init(ser serializer.Serializer) {
 	i.ser = ser
-	for _, service := range i.services {
-		i.reqDataTypes = append(i.reqDataTypes, service.GetReqDataTypes()...)
-		for _, dt := range service.GetReqDataTypes() {
-			i.reqServiceDataTypes[fmt.Sprintf("%#v", dt)] = service
+	for name, service := range i.services {
+		i.commands.register(name, service.GetReqDataTypes())
+		if queryService, ok := service.(QueryService); ok {
+			i.queries.register(name, queryService.GetQueryDataTypes())
+		}
+	}
+}
+
+// ObserveApply sets the observer notified about every applied request, it has to be set before the FSM is used
+func (i *RoutingFSM) ObserveApply(observer ApplyObserver) {
+	i.observer = observer
+}
+
+// EncodeCommand wraps the request into an Envelope addressed to the service which registered its type
+// and returns the serialized Envelope ready to be applied as a raft log
+func (i *RoutingFSM) EncodeCommand(request interface{}) ([]byte, error) {
+	return i.commands.encode(i.ser, request)
+}
+
+// EncodeQuery wraps the read-only request into an Envelope addressed to the QueryService which registered its type
+func (i *RoutingFSM) EncodeQuery(request interface{}) ([]byte, error) {
+	return i.queries.encode(i.ser, request)
+}
+
+// Query decodes a serialized read-only request and serves it from the state of the target QueryService,
+// it doesn't go through the raft log, so the caller is responsible for the consistency of the read
+func (i *RoutingFSM) Query(data []byte) interface{} {
+	serviceName, request, err := i.queries.decode(i.ser, data)
+	if err != nil {
+		return err
+	}
+	queryService, ok := i.services[serviceName].(QueryService)
+	if !ok {
+		return fmt.Errorf("service %s doesn't support queries", serviceName)
+	}
+	return queryService.Query(request)
+}
+
+// DecodeResponse deserializes the response of a request (command or query) which was served by another Node,
+// the response is decoded into its concrete type when the target service is a TypedFSMService
+func (i *RoutingFSM) DecodeResponse(request interface{}, data []byte) (interface{}, error) {
+	typeName := TypeName(request)
+	for _, registry := range []*typeRegistry{i.commands, i.queries} {
+		serviceName, err := registry.serviceOf(typeName)
+		if err != nil {
+			continue
+		}
+		if service, ok := i.services[serviceName].(TypedFSMService); ok {
+			if respType := service.GetRespDataType(typeName); respType != nil {
+				response := reflect.New(respType)
+				err := serializer.DeserializeInto(i.ser, data, response.Interface())
+				if err != nil {
+					return nil, err
+				}
+				return response.Elem().Interface(), nil
+			}
 		}
 	}
+	return i.ser.Deserialize(data)
 }
 
 func (i *RoutingFSM) Apply(log *raft.Log) interface{} {
 	switch log.Type {
 	case raft.LogCommand:
-		// deserialize
-		payload, err := i.ser.Deserialize(log.Data)
+		serviceName, request, err := i.commands.decode(i.ser, log.Data)
 		if err != nil {
 			return err
 		}
-		payloadMap := payload.(map[string]interface{})
-
-		// check request type
-		var fields []string
-		for k, _ := range payloadMap {
-			fields = append(fields, k)
+		if i.observer == nil {
+			return i.services[serviceName].NewLog(request)
 		}
-		// routing request to service
-		foundType, err := getTargetType(i.reqDataTypes, fields)
-		if err == nil {
-			for typeName, service := range i.reqServiceDataTypes {
-				if strings.EqualFold(fmt.Sprintf("%#v", foundType), typeName) {
-					return service.NewLog(foundType, payloadMap)
-				}
-			}
-		}
-		return errors.New("unknown request data type")
+		start := time.Now()
+		result := i.services[serviceName].NewLog(request)
+		_, failed := result.(error)
+		i.observer(serviceName, TypeName(request), time.Since(start), failed)
+		return result
 	}
 
 	return nil
 }
 
+// ApplyBatch applies a batch of committed logs in order, raft uses it instead of Apply to apply
+// up to MaxAppendEntries logs at once, the responses are in the same order as the logs
+func (i *RoutingFSM) ApplyBatch(logs []*raft.Log) []interface{} {
+	responses := make([]interface{}, len(logs))
+	for idx, l := range logs {
+		responses[idx] = i.Apply(l)
+	}
+	return responses
+}
+
+// Snapshot serializes the state of all the services right away, so the returned snapshot is not affected
+// by the logs applied while it is being persisted
 func (i *RoutingFSM) Snapshot() (raft.FSMSnapshot, error) {
-	return NewBaseFSMSnapshot(i), nil
+	servicesData := map[string]interface{}{}
+	for name, service := range i.services {
+		if provider, ok := service.(SnapshotDataProvider); ok {
+			servicesData[name] = provider.GetSnapshotData()
+		} else {
+			servicesData[name] = service
+		}
+	}
+	snapshotData, err := i.ser.Serialize(servicesData)
+	if err != nil {
+		return nil, err
+	}
+	return NewBaseFSMSnapshot(snapshotData), nil
 }
 
+// Restore replaces the state of all the services with the one stored in the snapshot,
+// it is called on startup and when a follower installs a snapshot received from the leader
 func (i *RoutingFSM) Restore(closer io.ReadCloser) error {
 	snapData, err := ioutil.ReadAll(closer)
 	if err != nil {
@@ -83,11 +157,13 @@ func (i *RoutingFSM) Restore(closer io.ReadCloser) error {
 	if err != nil {
 		return err
 	}
-	s := servicesData.(map[string]interface{})
+	s, ok := servicesData.(map[string]interface{})
+	if !ok {
+		return errors.New("invalid snapshot data")
+	}
 	for key, service := range i.services {
-		err = service.ApplySnapshot(s[key])
-		if err != nil {
-			log.Printf("Failed to apply snapshot to %q service!\n", key)
+		if err := service.ApplySnapshot(s[key]); err != nil {
+			return fmt.Errorf("failed to apply snapshot to %q service: %w", key, err)
 		}
 	}
 	return nil
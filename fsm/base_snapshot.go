@@ -2,28 +2,26 @@ package fsm
 
 import (
 	"github.com/hashicorp/raft"
-	"sync"
 )
 
+// BaseFSMSnapshot is a point-in-time snapshot of all the FSM services of a RoutingFSM
 type BaseFSMSnapshot struct {
-	sync.Mutex
-	fsm *RoutingFSM
+	data []byte
 }
 
-func NewBaseFSMSnapshot(fsm *RoutingFSM) raft.FSMSnapshot {
-	return &BaseFSMSnapshot{fsm: fsm}
+// NewBaseFSMSnapshot returns a snapshot which persists the already serialized services data
+func NewBaseFSMSnapshot(data []byte) raft.FSMSnapshot {
+	return &BaseFSMSnapshot{data: data}
 }
 
 func (i *BaseFSMSnapshot) Persist(sink raft.SnapshotSink) error {
-	i.Lock()
-	snapshotData, err := i.fsm.ser.Serialize(i.fsm.services)
+	_, err := sink.Write(i.data)
 	if err != nil {
+		_ = sink.Cancel()
 		return err
 	}
-	_, err = sink.Write(snapshotData)
-	return err
+	return sink.Close()
 }
 
 func (i *BaseFSMSnapshot) Release() {
-	i.Unlock()
 }
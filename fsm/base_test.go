@@ -0,0 +1,184 @@
+package fsm
+
+import (
+	"bytes"
+	"errors"
+	"github.com/hashicorp/raft"
+	"github.com/ksrichard/easyraft/serializer"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type counterAddRequest struct {
+	Name  string
+	Count int
+}
+
+// memorySnapshotSink is a raft.SnapshotSink keeping the persisted snapshot in memory
+type memorySnapshotSink struct {
+	bytes.Buffer
+	closed   bool
+	canceled bool
+}
+
+func (s *memorySnapshotSink) ID() string {
+	return "test"
+}
+
+func (s *memorySnapshotSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *memorySnapshotSink) Cancel() error {
+	s.canceled = true
+	return nil
+}
+
+// newCounterService returns a TypedService counting by name, its state is taken and restored by the snapshots,
+// restoring fails with restoreErr if it's set
+func newCounterService(restoreErr error) (*TypedService, func() map[string]int) {
+	var lock sync.Mutex
+	counters := map[string]int{}
+	svc := NewTypedService("counter")
+	RegisterHandler(svc, func(req counterAddRequest) (int, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		counters[req.Name] += req.Count
+		return counters[req.Name], nil
+	})
+	RegisterSnapshot(svc, func() map[string]int {
+		lock.Lock()
+		defer lock.Unlock()
+		state := map[string]int{}
+		for name, count := range counters {
+			state[name] = count
+		}
+		return state
+	}, func(state map[string]int) error {
+		if restoreErr != nil {
+			return restoreErr
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		counters = state
+		return nil
+	})
+	return svc, func() map[string]int {
+		lock.Lock()
+		defer lock.Unlock()
+		return counters
+	}
+}
+
+func newTestRoutingFSM(services ...FSMService) *RoutingFSM {
+	routing := NewRoutingFSM(services).(*RoutingFSM)
+	routing.Init(serializer.NewMsgPackSerializer())
+	return routing
+}
+
+func applyCommand(t *testing.T, routing *RoutingFSM, request interface{}) {
+	t.Helper()
+	data, err := routing.EncodeCommand(request)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if result := routing.Apply(&raft.Log{Type: raft.LogCommand, Data: data}); result != nil {
+		if err, ok := result.(error); ok {
+			t.Fatalf("apply failed: %v", err)
+		}
+	}
+}
+
+// persistSnapshot takes a snapshot of the FSM and persists it into a memory sink
+func persistSnapshot(t *testing.T, routing *RoutingFSM, beforePersist func()) *memorySnapshotSink {
+	t.Helper()
+	snapshot, err := routing.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	defer snapshot.Release()
+	beforePersist()
+	sink := &memorySnapshotSink{}
+	if err := snapshot.Persist(sink); err != nil {
+		t.Fatalf("persist failed: %v", err)
+	}
+	if !sink.closed || sink.canceled {
+		t.Fatalf("sink closed = %v, canceled = %v, want closed", sink.closed, sink.canceled)
+	}
+	return sink
+}
+
+func TestRoutingFSMSnapshotRestore(t *testing.T) {
+	mapService := NewInMemoryMapService().(*InMemoryMapService)
+	counter, _ := newCounterService(nil)
+	routing := newTestRoutingFSM(mapService, counter)
+	applyCommand(t, routing, MapPutRequest{MapName: "test", Key: "a", Value: "1"})
+	applyCommand(t, routing, counterAddRequest{Name: "a", Count: 2})
+
+	// the logs applied after the snapshot was taken are not part of it
+	sink := persistSnapshot(t, routing, func() {
+		applyCommand(t, routing, MapPutRequest{MapName: "test", Key: "b", Value: "2"})
+		applyCommand(t, routing, counterAddRequest{Name: "a", Count: 3})
+	})
+
+	restoredMap := NewInMemoryMapService().(*InMemoryMapService)
+	restoredMap.Put("test", "stale", "value")
+	restoredCounter, counters := newCounterService(nil)
+	restored := newTestRoutingFSM(restoredMap, restoredCounter)
+	if err := restored.Restore(ioutil.NopCloser(&sink.Buffer)); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	for key, want := range map[string]interface{}{"a": "1", "b": nil, "stale": nil} {
+		if got := restoredMap.Get("test", key); got != want {
+			t.Errorf("restored map value of %s = %v, want %v", key, got, want)
+		}
+	}
+	if got, want := counters(), map[string]int{"a": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("restored counters = %v, want %v", got, want)
+	}
+}
+
+func TestRoutingFSMRestoreFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		snapshot   []byte
+		restoreErr error
+		wantErr    string
+	}{
+		{
+			name:       "service failing to apply the snapshot",
+			restoreErr: errors.New("corrupt state"),
+			wantErr:    `failed to apply snapshot to "counter" service: corrupt state`,
+		},
+		{
+			name:     "snapshot without services data",
+			snapshot: []byte{0xc0},
+			wantErr:  "invalid snapshot data",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, _ := newCounterService(nil)
+			routing := newTestRoutingFSM(counter)
+			applyCommand(t, routing, counterAddRequest{Name: "a", Count: 1})
+			data := tt.snapshot
+			if data == nil {
+				data = persistSnapshot(t, routing, func() {}).Bytes()
+			}
+
+			restoredCounter, _ := newCounterService(tt.restoreErr)
+			err := newTestRoutingFSM(restoredCounter).Restore(ioutil.NopCloser(bytes.NewReader(data)))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("restore error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.restoreErr != nil && !errors.Is(err, tt.restoreErr) {
+				t.Errorf("restore error %v doesn't wrap %v", err, tt.restoreErr)
+			}
+		})
+	}
+}
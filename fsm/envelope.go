@@ -0,0 +1,40 @@
+package fsm
+
+import (
+	"reflect"
+)
+
+// Envelope wraps every command applied to the raft cluster, it carries the name of the target service
+// and the registered type name of the request, so the RoutingFSM can route it deterministically and
+// decode the payload into the concrete request type
+type Envelope struct {
+	Service string
+	Type    string
+	Payload []byte
+}
+
+// TypeName returns the registered type name of a request (package path and type name), pointers are dereferenced
+func TypeName(request interface{}) string {
+	return typeName(reflect.TypeOf(request))
+}
+
+func typeName(t reflect.Type) string {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return ""
+	}
+	if t.PkgPath() == "" {
+		return t.String()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+// dereference returns the type of a request with all the pointers dereferenced
+func dereference(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
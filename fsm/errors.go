@@ -0,0 +1,62 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// UnknownErrorCode is the code of application errors created from errors without a code
+const UnknownErrorCode = "unknown"
+
+// ApplicationError is a structured error returned by FSM services, its code, message and details are kept
+// when the request has been forwarded to the Leader Node, so callers get the same error on every Node
+type ApplicationError struct {
+	Code    string
+	Message string
+	Details map[string]string
+
+	// cause is the original error, it is available only on the Node where the error happened
+	cause error
+}
+
+// NewApplicationError returns a new ApplicationError with the given code and message
+func NewApplicationError(code, message string) *ApplicationError {
+	return &ApplicationError{Code: code, Message: message}
+}
+
+// ToApplicationError returns err as an ApplicationError, errors which are not (and don't wrap) an ApplicationError
+// are converted to one with UnknownErrorCode
+func ToApplicationError(err error) *ApplicationError {
+	var appErr *ApplicationError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return &ApplicationError{Code: UnknownErrorCode, Message: err.Error(), cause: err}
+}
+
+// WithDetail adds a detail to the error and returns it
+func (e *ApplicationError) WithDetail(key, value string) *ApplicationError {
+	if e.Details == nil {
+		e.Details = map[string]string{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+func (e *ApplicationError) Error() string {
+	if e.Code == "" || e.Code == UnknownErrorCode {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Unwrap returns the original error of an ApplicationError created with ToApplicationError
+func (e *ApplicationError) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether the target is an ApplicationError with the same code
+func (e *ApplicationError) Is(target error) bool {
+	t, ok := target.(*ApplicationError)
+	return ok && t.Code == e.Code && e.Code != UnknownErrorCode
+}
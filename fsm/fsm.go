@@ -12,6 +12,18 @@ type FSM interface {
 	// Init is used to pass the original serializer from EasyRaft Node to be able to deserialize messages
 	// coming from other nodes
 	Init(ser serializer.Serializer)
+
+	// EncodeCommand is used to turn a request into the raft log payload which is routed to the right FSMService
+	EncodeCommand(request interface{}) ([]byte, error)
+
+	// EncodeQuery is used to turn a read-only request into a payload which is routed to the right QueryService
+	EncodeQuery(request interface{}) ([]byte, error)
+
+	// Query is used to serve an encoded read-only request from the local state, without going through the raft log
+	Query(data []byte) interface{}
+
+	// DecodeResponse is used to deserialize the response of a request applied (or queried) on the leader Node
+	DecodeResponse(request interface{}, data []byte) (interface{}, error)
 }
 
 // FSMService interface makes it easier to build State Machines
@@ -19,13 +31,28 @@ type FSMService interface {
 	// Name returns the unique ID/Name which will identify the FSM Service when it comes to routing incoming messages
 	Name() string
 
-	// NewLog is called when a new raft log message is committed in the cluster and matched with any of the GetReqDataTypes returned types
+	// NewLog is called when a new raft log message is committed in the cluster and addressed to this service,
+	// the request is already decoded into one of the GetReqDataTypes returned types (as a value, not a pointer)
 	// in this method we can handle what should happen when we got a new raft log regarding our FSM service
-	NewLog(requestType interface{}, request map[string]interface{}) interface{}
+	NewLog(request interface{}) interface{}
 
-	// GetReqDataTypes returns all the request structs which are used by this FSMService
+	// GetReqDataTypes returns all the request structs which are used by this FSMService,
+	// every type is registered by its package path and name (see TypeName)
 	GetReqDataTypes() []interface{}
 
 	// ApplySnapshot is used to decode and apply a snapshot to the FSMService
 	ApplySnapshot(input interface{}) error
 }
+
+// QueryService is an optional extension of FSMService for services which are able to serve read-only requests
+// directly from their state, without appending them to the raft log
+type QueryService interface {
+	FSMService
+
+	// GetQueryDataTypes returns all the read-only request structs which are served by Query
+	GetQueryDataTypes() []interface{}
+
+	// Query is called with a read-only request decoded into one of the GetQueryDataTypes returned types,
+	// it must not modify the state of the service
+	Query(request interface{}) interface{}
+}
@@ -46,33 +46,23 @@ func (m *InMemoryMapService) ApplySnapshot(input interface{}) error {
 	if err != nil {
 		return err
 	}
+	if svc.Maps == nil {
+		svc.Maps = map[string]*Map{}
+	}
+	m.Lock()
+	defer m.Unlock()
 	m.Maps = svc.Maps
 	return nil
 }
 
-func (m *InMemoryMapService) NewLog(requestType interface{}, request map[string]interface{}) interface{} {
-	switch requestType.(type) {
+func (m *InMemoryMapService) NewLog(request interface{}) interface{} {
+	switch req := request.(type) {
 	case MapPutRequest:
-		var req MapPutRequest
-		err := mapstructure.Decode(request, &req)
-		if err != nil {
-			return err
-		}
 		m.Put(req.MapName, req.Key, req.Value)
 		return nil
 	case MapGetRequest:
-		var req MapGetRequest
-		err := mapstructure.Decode(request, &req)
-		if err != nil {
-			return err
-		}
 		return m.Get(req.MapName, req.Key)
 	case MapRemoveRequest:
-		var req MapRemoveRequest
-		err := mapstructure.Decode(request, &req)
-		if err != nil {
-			return err
-		}
 		m.Remove(req.MapName, req.Key)
 		return nil
 	default:
@@ -84,6 +74,19 @@ func (m *InMemoryMapService) GetReqDataTypes() []interface{} {
 	return []interface{}{MapPutRequest{}, MapGetRequest{}, MapRemoveRequest{}}
 }
 
+func (m *InMemoryMapService) GetQueryDataTypes() []interface{} {
+	return []interface{}{MapGetRequest{}}
+}
+
+func (m *InMemoryMapService) Query(request interface{}) interface{} {
+	switch req := request.(type) {
+	case MapGetRequest:
+		return m.Get(req.MapName, req.Key)
+	default:
+		return errors.New("unknown query type")
+	}
+}
+
 func (m *InMemoryMapService) Put(mapName string, key string, value interface{}) {
 	m.Lock()
 	defer m.Unlock()
@@ -102,8 +105,7 @@ func (m *InMemoryMapService) Get(mapName string, key string) interface{} {
 	defer m.RUnlock()
 	fMap, found := m.Maps[mapName]
 	if !found {
-		m.Maps[mapName] = &Map{Data: map[string]interface{}{}}
-		fMap = m.Maps[mapName]
+		return nil
 	}
 	fMap.RLock()
 	defer fMap.RUnlock()
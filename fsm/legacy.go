@@ -0,0 +1,60 @@
+package fsm
+
+import (
+	"github.com/mitchellh/mapstructure"
+)
+
+// LegacyFSMService is the FSMService interface of the previous releases, where NewLog received one of the
+// GetReqDataTypes returned values to switch on and the request as a generic map. Wrap it with NewLegacyService
+// to keep such a service working without changes.
+type LegacyFSMService interface {
+	Name() string
+	NewLog(requestType interface{}, request map[string]interface{}) interface{}
+	GetReqDataTypes() []interface{}
+	ApplySnapshot(input interface{}) error
+}
+
+// LegacyService adapts a LegacyFSMService to FSMService
+type LegacyService struct {
+	service   LegacyFSMService
+	dataTypes map[string]interface{}
+}
+
+// NewLegacyService returns an FSMService which passes the requests to the NewLog of the given LegacyFSMService
+// the same way as the previous releases did: the matching GetReqDataTypes value and the request fields as a map
+func NewLegacyService(service LegacyFSMService) *LegacyService {
+	dataTypes := map[string]interface{}{}
+	for _, dt := range service.GetReqDataTypes() {
+		dataTypes[TypeName(dt)] = dt
+	}
+	return &LegacyService{service: service, dataTypes: dataTypes}
+}
+
+func (s *LegacyService) Name() string {
+	return s.service.Name()
+}
+
+func (s *LegacyService) NewLog(request interface{}) interface{} {
+	requestMap := map[string]interface{}{}
+	err := mapstructure.Decode(request, &requestMap)
+	if err != nil {
+		return err
+	}
+	return s.service.NewLog(s.dataTypes[TypeName(request)], requestMap)
+}
+
+func (s *LegacyService) GetReqDataTypes() []interface{} {
+	return s.service.GetReqDataTypes()
+}
+
+func (s *LegacyService) ApplySnapshot(input interface{}) error {
+	return s.service.ApplySnapshot(input)
+}
+
+// GetSnapshotData returns the snapshot data of the wrapped service, so the snapshots stay compatible
+func (s *LegacyService) GetSnapshotData() interface{} {
+	if provider, ok := s.service.(SnapshotDataProvider); ok {
+		return provider.GetSnapshotData()
+	}
+	return s.service
+}
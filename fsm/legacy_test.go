@@ -0,0 +1,104 @@
+package fsm
+
+import (
+	"github.com/hashicorp/raft"
+	"github.com/ksrichard/easyraft/serializer"
+	"github.com/mitchellh/mapstructure"
+	"reflect"
+	"testing"
+)
+
+type legacyAddRequest struct {
+	Name  string
+	Count int
+}
+
+type legacyResetRequest struct {
+	Name string
+}
+
+// legacyCounter is a service implementing the NewLog signature of the previous releases
+type legacyCounter struct {
+	Counters map[string]int
+}
+
+func (c *legacyCounter) Name() string {
+	return "legacy_counter"
+}
+
+func (c *legacyCounter) NewLog(requestType interface{}, request map[string]interface{}) interface{} {
+	switch requestType.(type) {
+	case legacyAddRequest:
+		var req legacyAddRequest
+		if err := mapstructure.Decode(request, &req); err != nil {
+			return err
+		}
+		c.Counters[req.Name] += req.Count
+		return c.Counters[req.Name]
+	case legacyResetRequest:
+		var req legacyResetRequest
+		if err := mapstructure.Decode(request, &req); err != nil {
+			return err
+		}
+		delete(c.Counters, req.Name)
+		return 0
+	default:
+		return NewApplicationError("unknown_request", "unknown request type")
+	}
+}
+
+func (c *legacyCounter) GetReqDataTypes() []interface{} {
+	return []interface{}{legacyAddRequest{}, legacyResetRequest{}}
+}
+
+func (c *legacyCounter) ApplySnapshot(input interface{}) error {
+	return mapstructure.Decode(input, c)
+}
+
+func TestLegacyService(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests []interface{}
+		want     []interface{}
+		counters map[string]int
+	}{
+		{
+			name:     "value requests",
+			requests: []interface{}{legacyAddRequest{Name: "a", Count: 2}, legacyAddRequest{Name: "a", Count: 3}},
+			want:     []interface{}{2, 5},
+			counters: map[string]int{"a": 5},
+		},
+		{
+			name:     "pointer requests",
+			requests: []interface{}{&legacyAddRequest{Name: "a", Count: 1}, &legacyResetRequest{Name: "a"}},
+			want:     []interface{}{1, 0},
+			counters: map[string]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := &legacyCounter{Counters: map[string]int{}}
+			routing := NewRoutingFSM([]FSMService{NewLegacyService(counter)}).(*RoutingFSM)
+			routing.Init(serializer.NewMsgPackSerializer())
+			for i, request := range tt.requests {
+				data, err := routing.EncodeCommand(request)
+				if err != nil {
+					t.Fatalf("encode failed: %v", err)
+				}
+				if got := routing.Apply(&raft.Log{Type: raft.LogCommand, Data: data}); got != tt.want[i] {
+					t.Errorf("response %d = %#v, want %#v", i, got, tt.want[i])
+				}
+			}
+			if !reflect.DeepEqual(counter.Counters, tt.counters) {
+				t.Errorf("counters = %v, want %v", counter.Counters, tt.counters)
+			}
+		})
+	}
+}
+
+func TestLegacyServiceSnapshotData(t *testing.T) {
+	counter := &legacyCounter{Counters: map[string]int{"a": 1}}
+	if got := NewLegacyService(counter).GetSnapshotData(); got != counter {
+		t.Errorf("snapshot data = %#v, want the wrapped service", got)
+	}
+}
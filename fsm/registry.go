@@ -0,0 +1,97 @@
+package fsm
+
+import (
+	"fmt"
+	"github.com/ksrichard/easyraft/serializer"
+	"reflect"
+	"sort"
+)
+
+// typeRegistry keeps track of the request types registered by the services,
+// it is used to wrap requests into envelopes and to decode them into their concrete types
+type typeRegistry struct {
+	// types contains all the registered request types by type name
+	types map[string]reflect.Type
+
+	// services contains the names of the services handling a request type by type name
+	services map[string][]string
+}
+
+func newTypeRegistry() *typeRegistry {
+	return &typeRegistry{
+		types:    map[string]reflect.Type{},
+		services: map[string][]string{},
+	}
+}
+
+// register registers the given request data types for the service
+func (r *typeRegistry) register(serviceName string, dataTypes []interface{}) {
+	for _, dt := range dataTypes {
+		t := dereference(reflect.TypeOf(dt))
+		typeName := typeName(t)
+		r.types[typeName] = t
+		r.services[typeName] = append(r.services[typeName], serviceName)
+		sort.Strings(r.services[typeName])
+	}
+}
+
+// serviceOf returns the name of the only service which registered the request type
+func (r *typeRegistry) serviceOf(typeName string) (string, error) {
+	serviceNames := r.services[typeName]
+	switch len(serviceNames) {
+	case 0:
+		return "", fmt.Errorf("unknown request data type: %s", typeName)
+	case 1:
+		return serviceNames[0], nil
+	default:
+		return "", fmt.Errorf("request data type %s is registered by multiple services: %v", typeName, serviceNames)
+	}
+}
+
+// encode wraps the request into an Envelope addressed to the service which registered its type
+// and returns the serialized Envelope
+func (r *typeRegistry) encode(ser serializer.Serializer, request interface{}) ([]byte, error) {
+	typeName := TypeName(request)
+	serviceName, err := r.serviceOf(typeName)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := ser.Serialize(request)
+	if err != nil {
+		return nil, err
+	}
+	return ser.Serialize(Envelope{
+		Service: serviceName,
+		Type:    typeName,
+		Payload: payload,
+	})
+}
+
+// decode decodes a serialized Envelope and its payload into the concrete request type
+// and returns the name of the target service with the request
+func (r *typeRegistry) decode(ser serializer.Serializer, data []byte) (string, interface{}, error) {
+	var envelope Envelope
+	err := serializer.DeserializeInto(ser, data, &envelope)
+	if err != nil {
+		return "", nil, err
+	}
+	reqType, found := r.types[envelope.Type]
+	if !found || !containsString(r.services[envelope.Type], envelope.Service) {
+		return "", nil, fmt.Errorf("unknown request data type %s for service %s", envelope.Type, envelope.Service)
+	}
+	request := reflect.New(reqType)
+	err = serializer.DeserializeInto(ser, envelope.Payload, request.Interface())
+	if err != nil {
+		return "", nil, err
+	}
+	return envelope.Service, request.Elem().Interface(), nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package fsm
+
+import (
+	"github.com/ksrichard/easyraft/serializer"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type putRequest struct {
+	Key   string
+	Value string
+}
+
+type deleteRequest struct {
+	Key string
+}
+
+type sharedRequest struct {
+	Key string
+}
+
+func newTestRegistry() *typeRegistry {
+	r := newTypeRegistry()
+	r.register("kv", []interface{}{putRequest{}, &deleteRequest{}, sharedRequest{}})
+	r.register("audit", []interface{}{sharedRequest{}})
+	return r
+}
+
+func TestTypeRegistryRouting(t *testing.T) {
+	ser := serializer.NewMsgPackSerializer()
+	tests := []struct {
+		name        string
+		request     interface{}
+		wantService string
+		wantRequest interface{}
+		wantErr     string
+	}{
+		{
+			name:        "value request",
+			request:     putRequest{Key: "a", Value: "1"},
+			wantService: "kv",
+			wantRequest: putRequest{Key: "a", Value: "1"},
+		},
+		{
+			name:        "pointer request registered as pointer",
+			request:     &deleteRequest{Key: "a"},
+			wantService: "kv",
+			wantRequest: deleteRequest{Key: "a"},
+		},
+		{
+			name:        "pointer request registered as value",
+			request:     &putRequest{Key: "b"},
+			wantService: "kv",
+			wantRequest: putRequest{Key: "b"},
+		},
+		{
+			name:    "unknown request type",
+			request: struct{ Key string }{Key: "a"},
+			wantErr: "unknown request data type",
+		},
+		{
+			name:    "request type registered by multiple services",
+			request: sharedRequest{Key: "a"},
+			wantErr: "registered by multiple services: [audit kv]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry()
+			data, err := r.encode(ser, tt.request)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("encode error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+			serviceName, request, err := r.decode(ser, data)
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if serviceName != tt.wantService {
+				t.Errorf("service = %s, want %s", serviceName, tt.wantService)
+			}
+			if !reflect.DeepEqual(request, tt.wantRequest) {
+				t.Errorf("request = %#v, want %#v", request, tt.wantRequest)
+			}
+		})
+	}
+}
+
+func TestTypeRegistryDecodeEnvelope(t *testing.T) {
+	ser := serializer.NewMsgPackSerializer()
+	tests := []struct {
+		name        string
+		envelope    Envelope
+		wantService string
+		wantErr     bool
+	}{
+		{
+			name:        "colliding type addressed to the first service",
+			envelope:    Envelope{Service: "audit", Type: TypeName(sharedRequest{})},
+			wantService: "audit",
+		},
+		{
+			name:        "colliding type addressed to the second service",
+			envelope:    Envelope{Service: "kv", Type: TypeName(sharedRequest{})},
+			wantService: "kv",
+		},
+		{
+			name:     "type not registered by the service",
+			envelope: Envelope{Service: "audit", Type: TypeName(putRequest{})},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown service",
+			envelope: Envelope{Service: "missing", Type: TypeName(putRequest{})},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown type",
+			envelope: Envelope{Service: "kv", Type: "missing.Request"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := ser.Serialize(sharedRequest{Key: "a"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.envelope.Payload = payload
+			data, err := ser.Serialize(tt.envelope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			serviceName, _, err := newTestRegistry().decode(ser, data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decode succeeded for service %s, want error", serviceName)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if serviceName != tt.wantService {
+				t.Errorf("service = %s, want %s", serviceName, tt.wantService)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// TypedFSMService is an optional extension of FSMService for services which declare the response type of their requests,
+// so responses of requests forwarded to the leader can be decoded into the right Go type instead of a generic value
+type TypedFSMService interface {
+	FSMService
+
+	// GetRespDataType returns the response type of the given registered request type name, or nil if it's unknown
+	GetRespDataType(requestTypeName string) reflect.Type
+}
+
+// SnapshotDataProvider is an optional extension of FSMService for services which provide their own snapshot data,
+// instead of the service itself being serialized into the snapshot
+type SnapshotDataProvider interface {
+	// GetSnapshotData returns the data of the service which is serialized into the snapshot
+	GetSnapshotData() interface{}
+}
+
+// TypedService is an FSMService built from typed request handlers registered with RegisterHandler
+// and RegisterQueryHandler, requests are passed to the handlers already decoded into their concrete types
+type TypedService struct {
+	sync.RWMutex
+	name           string
+	handlers       map[string]func(request interface{}) interface{}
+	reqDataTypes   []interface{}
+	queryHandlers  map[string]func(request interface{}) interface{}
+	queryDataTypes []interface{}
+	respDataTypes  map[string]reflect.Type
+	snapshot       func() interface{}
+	restore        func(input interface{}) error
+}
+
+// NewTypedService returns an empty TypedService with the given unique name, handlers can be added using RegisterHandler
+func NewTypedService(name string) *TypedService {
+	return &TypedService{
+		name:          name,
+		handlers:      map[string]func(request interface{}) interface{}{},
+		queryHandlers: map[string]func(request interface{}) interface{}{},
+		respDataTypes: map[string]reflect.Type{},
+	}
+}
+
+// RegisterHandler registers a typed handler for the Req request type on the service,
+// Req must be a struct or a pointer to a struct and every request type can have only one handler.
+// The response (or the error) of the handler is returned to the RaftApply caller.
+// Handlers must be registered before the Node is created.
+func RegisterHandler[Req any, Resp any](svc *TypedService, handler func(Req) (Resp, error)) {
+	svc.Lock()
+	defer svc.Unlock()
+	name, wrapped, dataType := wrapHandler(svc, svc.handlers, handler)
+	svc.handlers[name] = wrapped
+	svc.reqDataTypes = append(svc.reqDataTypes, dataType)
+}
+
+// RegisterQueryHandler registers a typed handler for the Req read-only request type on the service,
+// it is served by the Node read path without going through the raft log, so it must not modify any state.
+// Query handlers run concurrently with each other and with the command handlers applied by raft,
+// so they must synchronize the access to the state they share (e.g. with a sync.RWMutex).
+// Handlers must be registered before the Node is created.
+func RegisterQueryHandler[Req any, Resp any](svc *TypedService, handler func(Req) (Resp, error)) {
+	svc.Lock()
+	defer svc.Unlock()
+	name, wrapped, dataType := wrapHandler(svc, svc.queryHandlers, handler)
+	svc.queryHandlers[name] = wrapped
+	svc.queryDataTypes = append(svc.queryDataTypes, dataType)
+}
+
+// wrapHandler validates the request type of a typed handler, records its response type and returns
+// the type name of the request, the untyped handler and a value of the request type
+func wrapHandler[Req any, Resp any](svc *TypedService, handlers map[string]func(request interface{}) interface{}, handler func(Req) (Resp, error)) (string, func(request interface{}) interface{}, interface{}) {
+	reqType := reflect.TypeOf((*Req)(nil)).Elem()
+	valueType := dereference(reqType)
+	if valueType.Kind() != reflect.Struct || (reqType.Kind() == reflect.Ptr && reqType.Elem().Kind() == reflect.Ptr) {
+		panic(fmt.Sprintf("request type %s must be a struct or a pointer to a struct", reqType))
+	}
+	name := typeName(valueType)
+	if _, found := handlers[name]; found {
+		panic(fmt.Sprintf("handler for request type %s is already registered in %s service", name, svc.name))
+	}
+	respType := reflect.TypeOf((*Resp)(nil)).Elem()
+	if registeredType, found := svc.respDataTypes[name]; found && registeredType != respType {
+		panic(fmt.Sprintf("request type %s is already registered with %s response type in %s service", name, registeredType, svc.name))
+	}
+	svc.respDataTypes[name] = respType
+
+	wrapped := func(request interface{}) interface{} {
+		typedReq, ok := request.(Req)
+		if !ok {
+			// requests are decoded as values, handlers of pointer types are getting a pointer to them
+			reqValue := reflect.ValueOf(request)
+			if reqType.Kind() != reflect.Ptr || reqValue.Type() != valueType {
+				return fmt.Errorf("invalid request type %T for handler of %s", request, name)
+			}
+			ptr := reflect.New(valueType)
+			ptr.Elem().Set(reqValue)
+			typedReq = ptr.Interface().(Req)
+		}
+		resp, err := handler(typedReq)
+		if err != nil {
+			return err
+		}
+		return resp
+	}
+	return name, wrapped, reflect.Zero(valueType).Interface()
+}
+
+// RegisterSnapshot registers the functions to take and restore the state of the service during snapshots,
+// without them the service has no state in the snapshots
+func RegisterSnapshot[State any](svc *TypedService, snapshot func() State, restore func(State) error) {
+	svc.Lock()
+	defer svc.Unlock()
+	svc.snapshot = func() interface{} {
+		return snapshot()
+	}
+	svc.restore = func(input interface{}) error {
+		var state State
+		err := mapstructure.Decode(input, &state)
+		if err != nil {
+			return err
+		}
+		return restore(state)
+	}
+}
+
+func (s *TypedService) Name() string {
+	return s.name
+}
+
+func (s *TypedService) NewLog(request interface{}) interface{} {
+	s.RLock()
+	handler, found := s.handlers[TypeName(request)]
+	s.RUnlock()
+	if !found {
+		return errors.New("unknown request type")
+	}
+	return handler(request)
+}
+
+func (s *TypedService) GetReqDataTypes() []interface{} {
+	s.RLock()
+	defer s.RUnlock()
+	return sortedDataTypes(s.reqDataTypes)
+}
+
+func (s *TypedService) GetQueryDataTypes() []interface{} {
+	s.RLock()
+	defer s.RUnlock()
+	return sortedDataTypes(s.queryDataTypes)
+}
+
+func (s *TypedService) Query(request interface{}) interface{} {
+	s.RLock()
+	handler, found := s.queryHandlers[TypeName(request)]
+	s.RUnlock()
+	if !found {
+		return errors.New("unknown query type")
+	}
+	return handler(request)
+}
+
+func (s *TypedService) GetRespDataType(requestTypeName string) reflect.Type {
+	s.RLock()
+	defer s.RUnlock()
+	return s.respDataTypes[requestTypeName]
+}
+
+func (s *TypedService) GetSnapshotData() interface{} {
+	s.RLock()
+	defer s.RUnlock()
+	if s.snapshot == nil {
+		return nil
+	}
+	return s.snapshot()
+}
+
+func (s *TypedService) ApplySnapshot(input interface{}) error {
+	s.RLock()
+	defer s.RUnlock()
+	if s.restore == nil || input == nil {
+		return nil
+	}
+	return s.restore(input)
+}
+
+func sortedDataTypes(dataTypes []interface{}) []interface{} {
+	sorted := make([]interface{}, len(dataTypes))
+	copy(sorted, dataTypes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return TypeName(sorted[i]) < TypeName(sorted[j])
+	})
+	return sorted
+}
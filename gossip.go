@@ -0,0 +1,106 @@
+package easyraft
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/memberlist"
+	"net"
+	"strings"
+)
+
+// ErrGossipEncryptionDisabled is returned by the gossip key management methods when no gossip keys were configured
+var ErrGossipEncryptionDisabled = errors.New("gossip encryption is not enabled")
+
+// newGossipKeyring returns a memberlist keyring where the first key is the primary one,
+// it returns nil if there are no keys (gossip encryption disabled)
+func newGossipKeyring(keys [][]byte) (*memberlist.Keyring, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return memberlist.NewKeyring(keys, keys[0])
+}
+
+// parseJoinAllowlist parses the allowed IP addresses and CIDR ranges of the join allowlist
+func parseJoinAllowlist(entries []string) ([]*net.IPNet, error) {
+	var allowlist []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid join allowlist entry: %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			allowlist = append(allowlist, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid join allowlist entry: %q", entry)
+		}
+		allowlist = append(allowlist, ipNet)
+	}
+	return allowlist, nil
+}
+
+// isJoinAllowed checks the address of a joining Node against the join allowlist,
+// every address is allowed when there is no allowlist configured
+func (n *Node) isJoinAllowed(addr net.IP) bool {
+	if len(n.joinAllowlist) == 0 {
+		return true
+	}
+	for _, ipNet := range n.joinAllowlist {
+		if ipNet.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// gossipKeyring returns the keyring used for gossip encryption
+func (n *Node) gossipKeyring() (*memberlist.Keyring, error) {
+	if n.discoveryConfig.Keyring == nil {
+		return nil, ErrGossipEncryptionDisabled
+	}
+	return n.discoveryConfig.Keyring, nil
+}
+
+// InstallGossipKey adds a new key to the gossip keyring, it is used to decrypt messages but not for encryption until it
+// becomes the primary key with UseGossipKey.
+// Keys must be rotated on every Node: install the new key everywhere, use it everywhere, then remove the old one.
+func (n *Node) InstallGossipKey(key []byte) error {
+	keyring, err := n.gossipKeyring()
+	if err != nil {
+		return err
+	}
+	return keyring.AddKey(key)
+}
+
+// UseGossipKey changes the primary key used to encrypt gossip messages, the key must be already installed
+func (n *Node) UseGossipKey(key []byte) error {
+	keyring, err := n.gossipKeyring()
+	if err != nil {
+		return err
+	}
+	return keyring.UseKey(key)
+}
+
+// RemoveGossipKey removes a key from the gossip keyring, the primary key can not be removed
+func (n *Node) RemoveGossipKey(key []byte) error {
+	keyring, err := n.gossipKeyring()
+	if err != nil {
+		return err
+	}
+	return keyring.RemoveKey(key)
+}
+
+// ListGossipKeys returns all the installed gossip keys, the first one is the primary key
+func (n *Node) ListGossipKeys() ([][]byte, error) {
+	keyring, err := n.gossipKeyring()
+	if err != nil {
+		return nil, err
+	}
+	return keyring.GetKeys(), nil
+}
@@ -0,0 +1,120 @@
+package easyraft
+
+import (
+	"context"
+	"github.com/hashicorp/raft"
+	"github.com/ksrichard/easyraft/grpc"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// healthCheckInterval is how often the commit index of the leader is fetched
+// and the serving status of the gRPC health service is updated
+const healthCheckInterval = time.Second
+
+// leaderProgress is the commit index of the leader, as fetched by the last health check
+type leaderProgress struct {
+	sync.Mutex
+	leader      raft.ServerAddress
+	commitIndex uint64
+}
+
+// Ready returns true when the Node knows the leader and has applied the logs committed by the leader,
+// apart from at most Config.ReadyMaxLag logs. It's false while the Node is still replaying the log
+// (e.g. joining, installing a snapshot or lagging behind on replication).
+func (n *Node) Ready() bool {
+	return n.health().CaughtUp
+}
+
+// health returns the leader and catch-up state of the Node. The leader compares with its own commit index,
+// other nodes with the commit index of the leader fetched by the last health check, they are not caught up
+// until it's fetched from the actual leader.
+func (n *Node) health() HealthResponse {
+	leader := n.Raft.Leader()
+	state := HealthResponse{
+		HasLeader:    leader != "",
+		AppliedIndex: n.Raft.AppliedIndex(),
+	}
+	if !state.HasLeader {
+		return state
+	}
+	if leader == n.TransportManager.Transport().LocalAddr() {
+		state.LeaderCommitIndex = n.commitIndex()
+	} else {
+		n.leaderProgress.Lock()
+		known := n.leaderProgress.leader == leader
+		state.LeaderCommitIndex = n.leaderProgress.commitIndex
+		n.leaderProgress.Unlock()
+		if !known {
+			return state
+		}
+	}
+	state.CaughtUp = state.AppliedIndex+n.config.ReadyMaxLag >= state.LeaderCommitIndex
+	return state
+}
+
+// commitIndex returns the commit index known by this Node, on followers it's never higher than their last index
+func (n *Node) commitIndex() uint64 {
+	commitIndex, _ := strconv.ParseUint(n.Raft.Stats()["commit_index"], 10, 64)
+	return commitIndex
+}
+
+// registerHealthServer registers the standard gRPC health service (grpc.health.v1), the overall ("")
+// serving status reports whether the Node is Ready
+func (n *Node) registerHealthServer(server *ggrpc.Server) {
+	n.healthServer = health.NewServer()
+	n.healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(server, n.healthServer)
+}
+
+// runHealthChecks fetches the commit index of the leader and updates the serving status
+// of the gRPC health service until the Node is stopped
+func (n *Node) runHealthChecks() {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if atomic.LoadUint32(n.stopped) == 1 {
+			return
+		}
+		n.updateLeaderProgress()
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		if n.Ready() {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+		n.healthServer.SetServingStatus("", status)
+	}
+}
+
+// updateLeaderProgress fetches the commit index of the actual leader, the cached one is dropped when it fails
+func (n *Node) updateLeaderProgress() {
+	leader := n.Raft.Leader()
+	var commitIndex uint64
+	if leader != "" && leader != n.TransportManager.Transport().LocalAddr() {
+		details, err := n.fetchLeaderDetails(string(leader))
+		if err != nil {
+			leader = ""
+		} else {
+			commitIndex = details.CommitIndex
+		}
+	}
+	n.leaderProgress.Lock()
+	defer n.leaderProgress.Unlock()
+	n.leaderProgress.leader = leader
+	n.leaderProgress.commitIndex = commitIndex
+}
+
+// fetchLeaderDetails returns the details of the leader using the pooled connection
+func (n *Node) fetchLeaderDetails(address string) (*grpc.GetDetailsResponse, error) {
+	conn, err := n.connPool.get(address)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckInterval/2)
+	defer cancel()
+	return grpc.NewRaftClient(conn).GetDetails(ctx, &grpc.GetDetailsRequest{})
+}
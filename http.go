@@ -0,0 +1,186 @@
+package easyraft
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+)
+
+const httpShutdownTimeout = 2 * time.Second
+
+// StatusResponse is returned by the /v1/status endpoint of the HTTP API
+type StatusResponse struct {
+	ID            string            `json:"id"`
+	State         string            `json:"state"`
+	LeaderID      string            `json:"leaderId"`
+	LeaderAddress string            `json:"leaderAddress"`
+	Stats         map[string]string `json:"stats"`
+	Configuration []MemberResponse  `json:"configuration"`
+}
+
+// MemberResponse is a server of the raft cluster returned by the HTTP API
+type MemberResponse struct {
+	ID       string `json:"id"`
+	Address  string `json:"address"`
+	Suffrage string `json:"suffrage"`
+	Leader   bool   `json:"leader"`
+}
+
+// HealthResponse is returned by the /v1/health endpoint of the HTTP API
+type HealthResponse struct {
+	// HasLeader is true when the Node knows the leader of the cluster
+	HasLeader bool `json:"hasLeader"`
+
+	// CaughtUp is true when the Node is Ready: it has a leader and applied (almost) every committed log
+	CaughtUp bool `json:"caughtUp"`
+
+	// LeaderCommitIndex is the commit index of the leader, 0 when it's not known yet
+	LeaderCommitIndex uint64 `json:"leaderCommitIndex"`
+
+	AppliedIndex uint64 `json:"appliedIndex"`
+}
+
+// errorResponse is returned by the HTTP API when a request fails
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// startHTTPServer starts the HTTP admin and status API on Config.HTTPAddress
+func (n *Node) startHTTPServer() error {
+	listener, err := net.Listen("tcp", n.config.HTTPAddress)
+	if err != nil {
+		return err
+	}
+	if n.certs != nil {
+		// the same mutual TLS as the gRPC server, clients need a certificate signed by the CA of the cluster
+		listener = tls.NewListener(listener, &tls.Config{
+			GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+				return n.certs.serverConfig(), nil
+			},
+		})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/status", n.handleStatus)
+	mux.HandleFunc("/v1/members", n.handleMembers)
+	mux.HandleFunc("/v1/health", n.handleHealth)
+	mux.HandleFunc("/v1/snapshot", n.handleSnapshot)
+	if metricsHandler, err := n.MetricsHandler(); err == nil {
+		mux.Handle("/v1/metrics", metricsHandler)
+	}
+	n.httpServer = &http.Server{Handler: mux}
+	go func() {
+		if err := n.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			n.logger.Printf("HTTP server failed: %q\n", err.Error())
+		}
+	}()
+	n.logger.Printf("HTTP API listening on %s\n", listener.Addr())
+	return nil
+}
+
+// stopHTTPServer stops the HTTP API, waiting for the pending requests until the shutdown timeout
+func (n *Node) stopHTTPServer() {
+	if n.httpServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	if err := n.httpServer.Shutdown(ctx); err != nil {
+		n.logger.Printf("Failed to stop HTTP server: %q\n", err.Error())
+	}
+}
+
+// handleStatus returns the raft state, the leader, the raft stats and the local raft configuration
+func (n *Node) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	members, err := n.localMembers()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	leader := n.leaderChange(n.Raft.Leader())
+	writeJSON(w, http.StatusOK, StatusResponse{
+		ID:            n.ID,
+		State:         n.Raft.State().String(),
+		LeaderID:      leader.LeaderID,
+		LeaderAddress: leader.LeaderAddress,
+		Stats:         n.Raft.Stats(),
+		Configuration: membersToResponse(members),
+	})
+}
+
+// handleMembers returns the servers of the cluster as seen by the leader
+func (n *Node) handleMembers(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	members, err := n.Members(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, membersToResponse(members))
+}
+
+// handleHealth responds with 200 when the Node has a leader (liveness), or when it's also caught up
+// with the leader if the "ready" query parameter is set (readiness), otherwise with 503
+func (n *Node) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	health := n.health()
+	healthy := health.HasLeader
+	if _, ready := r.URL.Query()["ready"]; ready {
+		healthy = health.CaughtUp
+	}
+	status := http.StatusOK
+	if !healthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
+
+// handleSnapshot takes a snapshot of the state machine
+func (n *Node) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	if err := n.Snapshot(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowMethod responds with 405 when the request method is not the given one
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func membersToResponse(members []Member) []MemberResponse {
+	result := make([]MemberResponse, len(members))
+	for i, member := range members {
+		result[i] = MemberResponse{
+			ID:       member.ID,
+			Address:  member.Address,
+			Suffrage: member.Suffrage,
+			Leader:   member.Leader,
+		}
+	}
+	return result
+}
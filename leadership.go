@@ -0,0 +1,292 @@
+package easyraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/raft"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// leaderWaitTimeout is the maximum time to wait for a new leader after a leadership transfer
+const leaderWaitTimeout = 10 * time.Second
+
+// LeaderChange is sent to the leadership subscribers every time the leader of the cluster changes
+type LeaderChange struct {
+	// LeaderID is the ID of the new leader, it's empty when the cluster has no leader (e.g. during an election)
+	// or the leader is not in the local raft configuration yet (e.g. while this Node is joining)
+	LeaderID string
+
+	// LeaderAddress is the raft address of the new leader, it's empty when the cluster has no leader
+	LeaderAddress string
+
+	// IsLeader is true when this Node is the new leader
+	IsLeader bool
+}
+
+// leaderSubscribers holds the channels of the leadership subscribers
+type leaderSubscribers struct {
+	sync.Mutex
+	nextId   uint64
+	channels map[uint64]chan LeaderChange
+	closed   bool
+}
+
+// closeAll closes all the subscriptions, new subscriptions are closed right away
+func (s *leaderSubscribers) closeAll() {
+	s.Lock()
+	defer s.Unlock()
+	for id, ch := range s.channels {
+		close(ch)
+		delete(s.channels, id)
+	}
+	s.closed = true
+}
+
+// observeRaft registers the raft observers which track the leadership changes of the cluster
+// and the failing heartbeats of the peers. Leadership changes have their own blocking observer, so none of them
+// is dropped while the heartbeats of the peers are failing, their handling must not wait for the raft main loop.
+func (n *Node) observeRaft() {
+	n.leaderObsCh = make(chan raft.Observation, 16)
+	n.leaderObserver = raft.NewObserver(n.leaderObsCh, true, func(o *raft.Observation) bool {
+		_, ok := o.Data.(raft.LeaderObservation)
+		return ok
+	})
+	n.Raft.RegisterObserver(n.leaderObserver)
+	go func() {
+		for observation := range n.leaderObsCh {
+			n.handleLeaderChange(observation.Data.(raft.LeaderObservation).Leader)
+		}
+	}()
+
+	n.observationCh = make(chan raft.Observation, 16)
+	n.observer = raft.NewObserver(n.observationCh, false, func(o *raft.Observation) bool {
+		switch o.Data.(type) {
+		case raft.FailedHeartbeatObservation, raft.ResumedHeartbeatObservation:
+			return true
+		default:
+			return false
+		}
+	})
+	n.Raft.RegisterObserver(n.observer)
+	go func() {
+		for observation := range n.observationCh {
+			switch data := observation.Data.(type) {
+			case raft.FailedHeartbeatObservation:
+				n.handleFailedHeartbeat(data.PeerID)
+			case raft.ResumedHeartbeatObservation:
+				n.handleResumedHeartbeat(data.PeerID)
+			}
+		}
+	}()
+}
+
+// stopObservingRaft deregisters the raft observers registered in observeRaft and closes all the subscriptions
+func (n *Node) stopObservingRaft() {
+	if n.leaderObserver != nil {
+		n.Raft.DeregisterObserver(n.leaderObserver)
+		close(n.leaderObsCh)
+		n.leaderObserver = nil
+	}
+	if n.observer != nil {
+		n.Raft.DeregisterObserver(n.observer)
+		close(n.observationCh)
+		n.observer = nil
+	}
+	n.leaderSubs.closeAll()
+	n.eventSubs.closeAll()
+}
+
+// handleLeaderChange is called on every leadership change of the cluster
+func (n *Node) handleLeaderChange(leader raft.ServerAddress) {
+	epoch := atomic.AddUint64(&n.leadershipEpoch, 1)
+	atomic.StoreUint32(&n.leaseReady, 0)
+
+	// the connection to the previous leader is not needed for forwarding anymore
+	if n.lastLeader != "" && n.lastLeader != leader {
+		n.connPool.invalidate(string(n.lastLeader), n.config.ForwardTimeout)
+	}
+	n.lastLeader = leader
+
+	if leader == n.TransportManager.Transport().LocalAddr() {
+		if n.config.Role == RoleWitness {
+			go n.handOffLeadership()
+		} else {
+			go n.prepareLeaseReads(epoch)
+			go n.reconcileVoters()
+		}
+	}
+
+	change := n.leaderChange(leader)
+	n.publishLeaderChange(change)
+	n.publishEvent(LeadershipChanged, change.LeaderID, change.LeaderAddress)
+}
+
+// handOffLeadership transfers the leadership of a witness Node to the most up-to-date voter
+func (n *Node) handOffLeadership() {
+	n.logger.Println("Witness Node became the leader, transferring leadership...")
+	err := n.TransferLeadership("")
+	if err != nil {
+		n.logger.Printf("Failed to transfer leadership: %q\n", err.Error())
+	}
+}
+
+// prepareLeaseReads applies a barrier after this Node became the leader, so every log committed by the previous
+// leaders is applied before lease based reads are served from the local state machine
+func (n *Node) prepareLeaseReads(epoch uint64) {
+	err := n.Raft.Barrier(0).Error()
+	if err != nil {
+		n.logger.Printf("Failed to apply leadership barrier: %q\n", err.Error())
+		return
+	}
+	if atomic.LoadUint64(&n.leadershipEpoch) == epoch && n.Raft.State() == raft.Leader {
+		atomic.StoreUint32(&n.leaseReady, 1)
+	}
+}
+
+// IsLeader returns true when this Node is the leader of the cluster
+func (n *Node) IsLeader() bool {
+	return n.Raft.State() == raft.Leader
+}
+
+// LeaderAddress returns the raft address of the actual leader, or an empty string when there is no known leader
+func (n *Node) LeaderAddress() string {
+	return string(n.Raft.Leader())
+}
+
+// LeaderID returns the ID of the actual leader, or an empty string when there is no known leader
+func (n *Node) LeaderID() string {
+	return n.leaderChange(n.Raft.Leader()).LeaderID
+}
+
+// SubscribeLeaderChanges returns a channel which receives the actual leadership state right away and then every
+// leadership change, and a function to cancel the subscription. A slow subscriber only misses intermediate states:
+// the channel always holds the latest change. The channel is closed when the subscription is cancelled or the Node stops.
+func (n *Node) SubscribeLeaderChanges() (<-chan LeaderChange, func()) {
+	ch := make(chan LeaderChange, 1)
+	n.leaderSubs.Lock()
+	if n.leaderSubs.closed {
+		n.leaderSubs.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if n.leaderSubs.channels == nil {
+		n.leaderSubs.channels = map[uint64]chan LeaderChange{}
+	}
+	id := n.leaderSubs.nextId
+	n.leaderSubs.nextId++
+	n.leaderSubs.channels[id] = ch
+	ch <- n.leaderChange(n.Raft.Leader())
+	n.leaderSubs.Unlock()
+
+	return ch, func() {
+		n.leaderSubs.Lock()
+		defer n.leaderSubs.Unlock()
+		if _, ok := n.leaderSubs.channels[id]; ok {
+			close(ch)
+			delete(n.leaderSubs.channels, id)
+		}
+	}
+}
+
+// leaderChange returns the leadership state for the given leader address
+func (n *Node) leaderChange(leader raft.ServerAddress) LeaderChange {
+	change := LeaderChange{
+		LeaderAddress: string(leader),
+		IsLeader:      leader != "" && leader == n.TransportManager.Transport().LocalAddr(),
+	}
+	if leader == "" {
+		return change
+	}
+	for _, server := range n.Raft.GetConfiguration().Configuration().Servers {
+		if server.Address == leader {
+			change.LeaderID = string(server.ID)
+			break
+		}
+	}
+	return change
+}
+
+// publishLeaderChange sends the change to all the subscribers, replacing the change they haven't received yet
+func (n *Node) publishLeaderChange(change LeaderChange) {
+	n.leaderSubs.Lock()
+	defer n.leaderSubs.Unlock()
+	for _, ch := range n.leaderSubs.channels {
+		select {
+		case ch <- change:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- change
+		}
+	}
+}
+
+// TransferLeadership transfers the leadership of this Node to the voter with the given ID, or to the most up-to-date
+// voter when the ID is empty, and waits until the new leader takes over. It can only be called on the leader.
+func (n *Node) TransferLeadership(toID string) error {
+	if !n.IsLeader() {
+		return raft.ErrNotLeader
+	}
+	var future raft.Future
+	if toID == "" {
+		future = n.Raft.LeadershipTransfer()
+	} else {
+		server, err := n.transferTarget(toID)
+		if err != nil {
+			return err
+		}
+		future = n.Raft.LeadershipTransferToServer(server.ID, server.Address)
+	}
+	err := future.Error()
+	if err != nil {
+		return err
+	}
+	return n.waitForNewLeader(leaderWaitTimeout)
+}
+
+// transferTarget returns the server with the given ID if it's able to take over the leadership
+func (n *Node) transferTarget(id string) (raft.Server, error) {
+	if id == n.ID {
+		return raft.Server{}, errors.New("the leadership can't be transferred to the leader itself")
+	}
+	for _, server := range n.Raft.GetConfiguration().Configuration().Servers {
+		if server.ID != raft.ServerID(id) {
+			continue
+		}
+		if server.Suffrage != raft.Voter {
+			return raft.Server{}, fmt.Errorf("server %s is not a voter", id)
+		}
+		if meta, ok := n.aliveMembers()[id]; ok && meta.Role == RoleWitness {
+			return raft.Server{}, fmt.Errorf("server %s is a witness", id)
+		}
+		return server, nil
+	}
+	return raft.Server{}, fmt.Errorf("unknown server: %s", id)
+}
+
+// waitForNewLeader waits until another Node becomes the leader of the cluster
+func (n *Node) waitForNewLeader(timeout time.Duration) error {
+	localAddr := n.TransportManager.Transport().LocalAddr()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		leader := n.Raft.Leader()
+		if leader != "" && leader != localAddr {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return errors.New("timed out waiting for the new leader")
+}
+
+// leaveCluster removes this Node from the raft cluster, the removal is forwarded to the leader
+// if this Node is not the leader anymore
+func (n *Node) leaveCluster() error {
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.ForwardTimeout)
+	defer cancel()
+	return n.RemovePeer(ctx, n.ID)
+}
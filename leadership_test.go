@@ -0,0 +1,53 @@
+package easyraft
+
+import (
+	"testing"
+)
+
+func TestLeaderChangeIsObservedByAllNodes(t *testing.T) {
+	nodes := startTestCluster(t, 3)
+	leader := leaderOf(t, nodes)
+
+	subscriptions := make([]<-chan LeaderChange, len(nodes))
+	for i, node := range nodes {
+		ch, cancel := node.SubscribeLeaderChanges()
+		defer cancel()
+		subscriptions[i] = ch
+	}
+
+	var target *Node
+	for _, node := range nodes {
+		if node != leader {
+			target = node
+			break
+		}
+	}
+	if err := leader.TransferLeadership(target.ID); err != nil {
+		t.Fatalf("leadership transfer failed: %v", err)
+	}
+	waitUntil(t, "the target becomes the leader", target.IsLeader)
+
+	for i, node := range nodes {
+		ch := subscriptions[i]
+		var latest LeaderChange
+		waitUntil(t, "the subscriber receives the new leader", func() bool {
+			for {
+				select {
+				case change := <-ch:
+					latest = change
+				default:
+					return latest.LeaderID == target.ID
+				}
+			}
+		})
+		if latest.IsLeader != (node == target) {
+			t.Errorf("node %s: IsLeader = %t in the leader change, want %t", node.ID, latest.IsLeader, node == target)
+		}
+		if node.LeaderID() != target.ID {
+			t.Errorf("node %s: LeaderID() = %s, want %s", node.ID, node.LeaderID(), target.ID)
+		}
+		if node.IsLeader() != (node == target) {
+			t.Errorf("node %s: IsLeader() = %t, want %t", node.ID, node.IsLeader(), node == target)
+		}
+	}
+}
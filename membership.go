@@ -0,0 +1,179 @@
+package easyraft
+
+import (
+	"context"
+	"errors"
+	"github.com/hashicorp/raft"
+	"github.com/ksrichard/easyraft/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// forwardedMetadataKey marks the admin requests forwarded by another Node, they are never forwarded again
+const forwardedMetadataKey = "easyraft-forwarded"
+
+// Member is a server of the raft cluster
+type Member struct {
+	ID      string
+	Address string
+
+	// Suffrage is the raft suffrage of the server ("Voter", "Nonvoter" or "Staging")
+	Suffrage string
+
+	// Leader is true for the actual leader of the cluster
+	Leader bool
+}
+
+// AddPeer adds the server with the given ID and raft address to the cluster, nonvoters are added as nonvoters,
+// voters and witnesses as voters (an existing nonvoter is promoted). It is forwarded to the Leader Node automatically.
+func (n *Node) AddPeer(ctx context.Context, id string, address string, role Role) error {
+	if err := role.validate(); err != nil {
+		return err
+	}
+	err := n.addPeerLocal(id, address, role)
+	if err != raft.ErrNotLeader {
+		return err
+	}
+	return n.forwardAdmin(ctx, func(ctx context.Context, client grpc.RaftClient) error {
+		_, err := client.AddPeer(ctx, &grpc.AddPeerRequest{Id: id, Address: address, Role: string(role)})
+		return err
+	})
+}
+
+// RemovePeer removes the server with the given ID from the cluster, it is forwarded to the Leader Node automatically
+func (n *Node) RemovePeer(ctx context.Context, id string) error {
+	err := n.removePeerLocal(id)
+	if err != raft.ErrNotLeader {
+		return err
+	}
+	return n.forwardAdmin(ctx, func(ctx context.Context, client grpc.RaftClient) error {
+		_, err := client.RemovePeer(ctx, &grpc.RemovePeerRequest{Id: id})
+		return err
+	})
+}
+
+// Demote turns the voter with the given ID into a nonvoter, it is forwarded to the Leader Node automatically
+func (n *Node) Demote(ctx context.Context, id string) error {
+	err := n.demoteLocal(id)
+	if err != raft.ErrNotLeader {
+		return err
+	}
+	return n.forwardAdmin(ctx, func(ctx context.Context, client grpc.RaftClient) error {
+		_, err := client.Demote(ctx, &grpc.DemoteRequest{Id: id})
+		return err
+	})
+}
+
+// Members returns the servers of the cluster as seen by the Leader Node
+func (n *Node) Members(ctx context.Context) ([]Member, error) {
+	if n.IsLeader() {
+		return n.localMembers()
+	}
+	var members []Member
+	err := n.forwardAdmin(ctx, func(ctx context.Context, client grpc.RaftClient) error {
+		response, err := client.Members(ctx, &grpc.MembersRequest{})
+		if err != nil {
+			return err
+		}
+		members = membersFromProto(response.Members)
+		return nil
+	})
+	return members, err
+}
+
+func (n *Node) addPeerLocal(id string, address string, role Role) error {
+	if id == "" || address == "" {
+		return errors.New("peer ID and address must be set")
+	}
+	n.votersLock.Lock()
+	defer n.votersLock.Unlock()
+	if role.isVoter() {
+		return n.Raft.AddVoter(raft.ServerID(id), raft.ServerAddress(address), 0, 0).Error()
+	}
+	return n.Raft.AddNonvoter(raft.ServerID(id), raft.ServerAddress(address), 0, 0).Error()
+}
+
+func (n *Node) removePeerLocal(id string) error {
+	address := n.serverAddress(raft.ServerID(id))
+	err := n.Raft.RemoveServer(raft.ServerID(id), 0, 0).Error()
+	if err != nil {
+		return err
+	}
+	n.publishEvent(PeerRemoved, id, address)
+	if address != "" {
+		n.connPool.invalidate(address, n.config.ForwardTimeout)
+	}
+	go n.reconcileVoters()
+	return nil
+}
+
+func (n *Node) demoteLocal(id string) error {
+	n.votersLock.Lock()
+	defer n.votersLock.Unlock()
+	return n.Raft.DemoteVoter(raft.ServerID(id), 0, 0).Error()
+}
+
+func (n *Node) localMembers() ([]Member, error) {
+	future := n.Raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, err
+	}
+	leader := n.Raft.Leader()
+	var members []Member
+	for _, server := range future.Configuration().Servers {
+		members = append(members, Member{
+			ID:       string(server.ID),
+			Address:  string(server.Address),
+			Suffrage: server.Suffrage.String(),
+			Leader:   server.Address == leader,
+		})
+	}
+	return members, nil
+}
+
+// forwardAdmin calls an admin RPC on the actual Leader Node, the request is marked as forwarded
+func (n *Node) forwardAdmin(ctx context.Context, call func(ctx context.Context, client grpc.RaftClient) error) error {
+	client, err := n.leaderClient()
+	if err != nil {
+		n.metrics.forwardError("admin")
+		return err
+	}
+	ctx, cancel := n.forwardContext(ctx)
+	defer cancel()
+	err = call(metadata.AppendToOutgoingContext(ctx, forwardedMetadataKey, "true"), client)
+	if err != nil {
+		n.metrics.forwardError("admin")
+	}
+	return err
+}
+
+// isForwarded returns true when the incoming request was forwarded by another Node
+func isForwarded(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md.Get(forwardedMetadataKey)) > 0
+}
+
+func membersToProto(members []Member) []*grpc.Member {
+	result := make([]*grpc.Member, len(members))
+	for i, member := range members {
+		result[i] = &grpc.Member{
+			Id:       member.ID,
+			Address:  member.Address,
+			Suffrage: member.Suffrage,
+			Leader:   member.Leader,
+		}
+	}
+	return result
+}
+
+func membersFromProto(members []*grpc.Member) []Member {
+	result := make([]Member, len(members))
+	for i, member := range members {
+		result[i] = Member{
+			ID:       member.GetId(),
+			Address:  member.GetAddress(),
+			Suffrage: member.GetSuffrage(),
+			Leader:   member.GetLeader(),
+		}
+	}
+	return result
+}
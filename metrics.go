@@ -0,0 +1,319 @@
+package easyraft
+
+import (
+	"errors"
+	gometrics "github.com/armon/go-metrics"
+	gmprometheus "github.com/armon/go-metrics/prometheus"
+	"github.com/hashicorp/raft"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const metricsNamespace = "easyraft"
+
+// ErrMetricsDisabled is returned when the metrics handler is requested without metrics enabled
+var ErrMetricsDisabled = errors.New("metrics are disabled")
+
+// MetricsConfig configures the Prometheus metrics of the Node
+type MetricsConfig struct {
+	// Registerer is where the metrics are registered (default: a new registry, served by Node.MetricsHandler).
+	// The metrics have a node_id label, so the nodes of the same process can share a registerer.
+	Registerer prometheus.Registerer
+
+	// GoMetrics also exports the metrics of raft and memberlist (easyraft_raft_* and easyraft_memberlist_*).
+	// They are reported through the process-wide go-metrics instance, which is replaced by one forwarding to Prometheus,
+	// so leave it disabled when the application configures go-metrics itself (default: false).
+	GoMetrics bool
+}
+
+// raft and memberlist report their own metrics (easyraft_raft_* and easyraft_memberlist_*) through the global go-metrics
+// instance, with MetricsConfig.GoMetrics it's forwarded to a single Prometheus sink shared by every Node of the process,
+// which is registered once in every registerer
+var (
+	goMetricsLock        sync.Mutex
+	goMetricsSink        *gmprometheus.PrometheusSink
+	goMetricsRegisterers = map[prometheus.Registerer]bool{}
+)
+
+// registerGoMetrics forwards the go-metrics of raft and memberlist to the given registerer
+func registerGoMetrics(registerer prometheus.Registerer) error {
+	goMetricsLock.Lock()
+	defer goMetricsLock.Unlock()
+	if goMetricsSink == nil {
+		sink, err := gmprometheus.NewPrometheusSinkFrom(gmprometheus.PrometheusOpts{
+			Expiration: gmprometheus.DefaultPrometheusOpts.Expiration,
+			Registerer: prometheus.NewRegistry(),
+		})
+		if err != nil {
+			return err
+		}
+		conf := gometrics.DefaultConfig(metricsNamespace)
+		conf.EnableHostname = false
+		conf.EnableRuntimeMetrics = false
+		if _, err := gometrics.NewGlobal(conf, sink); err != nil {
+			return err
+		}
+		goMetricsSink = sink
+	}
+	if goMetricsRegisterers[registerer] {
+		return nil
+	}
+	if err := registerer.Register(goMetricsSink); err != nil {
+		return err
+	}
+	goMetricsRegisterers[registerer] = true
+	return nil
+}
+
+// metrics holds the Prometheus metrics of a Node, a nil *metrics records nothing
+type metrics struct {
+	registerer       prometheus.Registerer
+	nodeCollector    *nodeCollector
+	applyDuration    *prometheus.HistogramVec
+	forwardErrors    *prometheus.CounterVec
+	fsmApplies       *prometheus.CounterVec
+	fsmApplyDuration *prometheus.HistogramVec
+	events           *prometheus.CounterVec
+}
+
+func newMetrics(config MetricsConfig, nodeId string) (*metrics, error) {
+	registerer := config.Registerer
+	if registerer == nil {
+		registerer = prometheus.NewRegistry()
+	}
+	labels := prometheus.Labels{"node_id": nodeId}
+	m := &metrics{
+		registerer: registerer,
+		applyDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   metricsNamespace,
+			Name:        "apply_duration_seconds",
+			Help:        "Latency of RaftApply by path (local or forwarded to the leader).",
+			ConstLabels: labels,
+		}, []string{"path"}),
+		forwardErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "forward_errors_total",
+			Help:        "Number of failed calls forwarded to the leader by operation.",
+			ConstLabels: labels,
+		}, []string{"operation"}),
+		fsmApplies: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "fsm_apply_total",
+			Help:        "Number of requests applied by the FSM by service, request type and result.",
+			ConstLabels: labels,
+		}, []string{"service", "type", "result"}),
+		fsmApplyDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   metricsNamespace,
+			Name:        "fsm_apply_duration_seconds",
+			Help:        "Time spent applying requests in the FSM by service and request type.",
+			ConstLabels: labels,
+		}, []string{"service", "type"}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "events_total",
+			Help:        "Number of cluster events (discovery, membership and leadership changes) by type.",
+			ConstLabels: labels,
+		}, []string{"type"}),
+	}
+	// a Node recreated with the same ID (e.g. restarted in the same process) keeps counting on the existing metrics
+	var err error
+	if m.applyDuration, err = registerOrExisting(registerer, m.applyDuration); err != nil {
+		return nil, err
+	}
+	if m.forwardErrors, err = registerOrExisting(registerer, m.forwardErrors); err != nil {
+		return nil, err
+	}
+	if m.fsmApplies, err = registerOrExisting(registerer, m.fsmApplies); err != nil {
+		return nil, err
+	}
+	if m.fsmApplyDuration, err = registerOrExisting(registerer, m.fsmApplyDuration); err != nil {
+		return nil, err
+	}
+	if m.events, err = registerOrExisting(registerer, m.events); err != nil {
+		return nil, err
+	}
+	if config.GoMetrics {
+		if err := registerGoMetrics(registerer); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// registerOrExisting registers the collector, or returns the already registered one with the same descriptors
+func registerOrExisting[C prometheus.Collector](registerer prometheus.Registerer, collector C) (C, error) {
+	err := registerer.Register(collector)
+	if err == nil {
+		return collector, nil
+	}
+	if alreadyRegistered, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		if existing, ok := alreadyRegistered.ExistingCollector.(C); ok {
+			return existing, nil
+		}
+	}
+	return collector, err
+}
+
+// registerNode registers the collector of the raft and discovery state of the Node, until unregisterNode is called
+func (m *metrics) registerNode(node *Node) error {
+	if m == nil {
+		return nil
+	}
+	collector := newNodeCollector(node)
+	if err := m.registerer.Register(collector); err != nil {
+		return err
+	}
+	m.nodeCollector = collector
+	return nil
+}
+
+// unregisterNode unregisters the collector registered by registerNode, so the Node can be started again
+func (m *metrics) unregisterNode() {
+	if m == nil || m.nodeCollector == nil {
+		return
+	}
+	m.registerer.Unregister(m.nodeCollector)
+	m.nodeCollector = nil
+}
+
+// observeApply records the latency of a RaftApply call
+func (m *metrics) observeApply(forwarded bool, start time.Time) {
+	if m == nil {
+		return
+	}
+	path := "local"
+	if forwarded {
+		path = "forwarded"
+	}
+	m.applyDuration.WithLabelValues(path).Observe(time.Since(start).Seconds())
+}
+
+// forwardError counts a failed call forwarded to the leader
+func (m *metrics) forwardError(operation string) {
+	if m == nil {
+		return
+	}
+	m.forwardErrors.WithLabelValues(operation).Inc()
+}
+
+// observeFSMApply records a request applied by the FSM, it's used as fsm.ApplyObserver
+func (m *metrics) observeFSMApply(serviceName string, requestType string, duration time.Duration, failed bool) {
+	result := "success"
+	if failed {
+		result = "error"
+	}
+	m.fsmApplies.WithLabelValues(serviceName, requestType, result).Inc()
+	m.fsmApplyDuration.WithLabelValues(serviceName, requestType).Observe(duration.Seconds())
+}
+
+// event counts a published cluster event
+func (m *metrics) event(eventType EventType) {
+	if m == nil {
+		return
+	}
+	m.events.WithLabelValues(eventType.String()).Inc()
+}
+
+// MetricsHandler returns an HTTP handler serving the Prometheus metrics of the Node,
+// it's only available when metrics are enabled and the registerer is also a prometheus.Gatherer
+func (n *Node) MetricsHandler() (http.Handler, error) {
+	if n.metrics == nil {
+		return nil, ErrMetricsDisabled
+	}
+	gatherer, ok := n.metrics.registerer.(prometheus.Gatherer)
+	if !ok {
+		return nil, errors.New("the metrics registerer is not a gatherer")
+	}
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}), nil
+}
+
+// nodeCollector collects the state of the raft server and the discovery of a Node when it's scraped
+type nodeCollector struct {
+	node         *Node
+	state        *prometheus.Desc
+	term         *prometheus.Desc
+	commitIndex  *prometheus.Desc
+	appliedIndex *prometheus.Desc
+	lastLogIndex *prometheus.Desc
+	fsmPending   *prometheus.Desc
+	lastContact  *prometheus.Desc
+	peers        *prometheus.Desc
+	members      *prometheus.Desc
+	healthScore  *prometheus.Desc
+	raftStates   []raft.RaftState
+	statGauges   map[string]*prometheus.Desc
+}
+
+func newNodeCollector(node *Node) *nodeCollector {
+	labels := prometheus.Labels{"node_id": node.ID}
+	desc := func(name string, help string, variableLabels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", name), help, variableLabels, labels)
+	}
+	c := &nodeCollector{
+		node:         node,
+		state:        desc("node_state", "Raft state of the node, 1 for the actual state.", "state"),
+		term:         desc("node_term", "Current raft term."),
+		commitIndex:  desc("node_commit_index", "Last committed raft log index known by the node."),
+		appliedIndex: desc("node_applied_index", "Last raft log index applied to the FSM."),
+		lastLogIndex: desc("node_last_log_index", "Last raft log index stored by the node."),
+		fsmPending:   desc("node_fsm_pending", "Number of committed logs waiting to be applied to the FSM."),
+		lastContact:  desc("node_last_contact_seconds", "Time since the last contact with the leader, 0 on the leader."),
+		peers:        desc("node_peers", "Number of other voters in the raft configuration."),
+		members:      desc("discovery_members", "Number of alive discovery (memberlist) members."),
+		healthScore:  desc("discovery_health_score", "Memberlist health score, 0 is healthy, higher is worse."),
+		raftStates:   []raft.RaftState{raft.Follower, raft.Candidate, raft.Leader, raft.Shutdown},
+	}
+	c.statGauges = map[string]*prometheus.Desc{
+		"term":           c.term,
+		"commit_index":   c.commitIndex,
+		"applied_index":  c.appliedIndex,
+		"last_log_index": c.lastLogIndex,
+		"fsm_pending":    c.fsmPending,
+		"num_peers":      c.peers,
+	}
+	return c
+}
+
+func (c *nodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{c.state, c.lastContact, c.members, c.healthScore} {
+		ch <- desc
+	}
+	for _, desc := range c.statGauges {
+		ch <- desc
+	}
+}
+
+func (c *nodeCollector) Collect(ch chan<- prometheus.Metric) {
+	state := c.node.Raft.State()
+	for _, s := range c.raftStates {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, value, s.String())
+	}
+	stats := c.node.Raft.Stats()
+	for key, desc := range c.statGauges {
+		value, err := strconv.ParseUint(stats[key], 10, 64)
+		if err == nil {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(value))
+		}
+	}
+	switch lastContact := stats["last_contact"]; lastContact {
+	case "never":
+	case "0":
+		ch <- prometheus.MustNewConstMetric(c.lastContact, prometheus.GaugeValue, 0)
+	default:
+		if duration, err := time.ParseDuration(lastContact); err == nil {
+			ch <- prometheus.MustNewConstMetric(c.lastContact, prometheus.GaugeValue, duration.Seconds())
+		}
+	}
+	if mList := c.node.mList; mList != nil {
+		ch <- prometheus.MustNewConstMetric(c.members, prometheus.GaugeValue, float64(mList.NumMembers()))
+		ch <- prometheus.MustNewConstMetric(c.healthScore, prometheus.GaugeValue, float64(mList.GetHealthScore()))
+	}
+}
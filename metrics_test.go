@@ -0,0 +1,59 @@
+package easyraft
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"testing"
+)
+
+func TestMetricsNodeRestart(t *testing.T) {
+	tests := []struct {
+		name string
+		// recreate creates new metrics for the restarted Node instead of starting the same Node again
+		recreate bool
+	}{
+		{name: "same node started again"},
+		{name: "node recreated with the same ID", recreate: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := MetricsConfig{Registerer: prometheus.NewRegistry()}
+			node := &Node{ID: "node-1"}
+			m, err := newMetrics(config, node.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := m.registerNode(node); err != nil {
+				t.Fatalf("first registration failed: %v", err)
+			}
+			m.event(LeadershipChanged)
+			m.unregisterNode()
+
+			if tt.recreate {
+				restarted, err := newMetrics(config, node.ID)
+				if err != nil {
+					t.Fatalf("recreating the metrics failed: %v", err)
+				}
+				if restarted.events != m.events {
+					t.Error("the recreated metrics don't use the registered events counter")
+				}
+				m = restarted
+			}
+			if err := m.registerNode(node); err != nil {
+				t.Fatalf("registration after the restart failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestMetricsNodesShareRegisterer(t *testing.T) {
+	config := MetricsConfig{Registerer: prometheus.NewRegistry()}
+	for _, id := range []string{"node-1", "node-2"} {
+		m, err := newMetrics(config, id)
+		if err != nil {
+			t.Fatalf("metrics of %s: %v", id, err)
+		}
+		if err := m.registerNode(&Node{ID: id}); err != nil {
+			t.Fatalf("node collector of %s: %v", id, err)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package easyraft
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Jille/raft-grpc-transport"
+	"github.com/boltdb/bolt"
+	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/memberlist"
 	"github.com/hashicorp/raft"
 	raftboltdb "github.com/hashicorp/raft-boltdb"
@@ -14,12 +17,16 @@ import (
 	"github.com/ksrichard/easyraft/util"
 	"github.com/zemirco/uid"
 	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"io/ioutil"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -42,61 +49,235 @@ type Node struct {
 	logger           *log.Logger
 	stoppedCh        chan interface{}
 	snapshotEnabled  bool
+	hasExistingState bool
+	config           *Config
+	dialOptions      []ggrpc.DialOption
+	serverOptions    []ggrpc.ServerOption
+	certs            *certReloader
+	joinAllowlist    []*net.IPNet
+	fsm              fsm.FSM
+	connPool         *connPool
+	observer         *raft.Observer
+	observationCh    chan raft.Observation
+	leaderObserver   *raft.Observer
+	leaderObsCh      chan raft.Observation
+	leadershipEpoch  uint64
+	leaseReady       uint32
+	lastLeader       raft.ServerAddress
+	leaderSubs       leaderSubscribers
+	eventSubs        eventSubscribers
+	suspectPeers     sync.Map
+	votersLock       sync.Mutex
+	autopilot        *autopilot
+	coalescer        *coalescer
+	httpServer       *http.Server
+	healthServer     *health.Server
+	leaderProgress   leaderProgress
+	metrics          *metrics
+	stableStore      *raftboltdb.BoltStore
 }
 
+const (
+	nodeIdFileName          = "node.id"
+	stableStoreFileName     = "store.boltdb"
+	grpcGracefulStopTimeout = 2 * time.Second
+	boltOpenTimeout         = time.Second
+)
+
 // NewNode returns an EasyRaft node
 func NewNode(raftPort, discoveryPort int, dataDir string, services []fsm.FSMService, serializer serializer.Serializer, discoveryMethod discovery.DiscoveryMethod, snapshotEnabled bool) (*Node, error) {
-	// default raft config
-	addr := fmt.Sprintf("%s:%d", "0.0.0.0", raftPort)
-	nodeId := uid.New(50)
-	raftConf := raft.DefaultConfig()
-	raftConf.LocalID = raft.ServerID(nodeId)
-	raftLogCacheSize := 512
-	raftConf.LogLevel = "Info"
+	return NewNodeWithConfig(&Config{
+		RaftPort:        raftPort,
+		DiscoveryPort:   discoveryPort,
+		DataDir:         dataDir,
+		Services:        services,
+		Serializer:      serializer,
+		DiscoveryMethod: discoveryMethod,
+		SnapshotEnabled: snapshotEnabled,
+	})
+}
+
+// NewNodeWithConfig returns an EasyRaft node created from the given Config
+func NewNodeWithConfig(config *Config) (*Node, error) {
+	// config with defaults, the one passed in stays untouched
+	conf := *config
+	conf.setDefaults()
+	err := conf.validate()
+	if err != nil {
+		return nil, err
+	}
 
 	// stable/log/snapshot store config
+	dataDir := conf.DataDir
 	if !util.IsDir(dataDir) {
 		err := util.RemoveCreateDir(dataDir)
 		if err != nil {
 			return nil, err
 		}
 	}
-	stableStoreFile := filepath.Join(dataDir, "store.boltdb")
-	if util.FileExists(stableStoreFile) {
-		err := os.Remove(stableStoreFile)
-		if err != nil {
-			return nil, err
-		}
+	nodeId, err := loadNodeID(dataDir, conf.NodeID, conf.Durable)
+	if err != nil {
+		return nil, err
+	}
+
+	// addresses
+	addr := fmt.Sprintf("%s:%d", conf.BindAddress, conf.RaftPort)
+	advertiseHost := conf.AdvertiseAddress
+	if advertiseHost == "" {
+		advertiseHost = conf.BindAddress
+	}
+	advertiseAddr := fmt.Sprintf("%s:%d", advertiseHost, conf.RaftPort)
+
+	// raft config
+	raftConf := raft.DefaultConfig()
+	raftConf.LocalID = raft.ServerID(nodeId)
+	raftConf.LogLevel = conf.LogLevel
+	// raft logs through the logger of the Node
+	raftConf.Logger = hclog.FromStandardLogger(conf.Logger, &hclog.LoggerOptions{
+		Name:  "raft",
+		Level: hclog.LevelFromString(conf.LogLevel),
+	})
+	if conf.HeartbeatTimeout > 0 {
+		raftConf.HeartbeatTimeout = conf.HeartbeatTimeout
+	}
+	if conf.ElectionTimeout > 0 {
+		raftConf.ElectionTimeout = conf.ElectionTimeout
+	}
+	if conf.CommitTimeout > 0 {
+		raftConf.CommitTimeout = conf.CommitTimeout
 	}
-	stableStore, err := raftboltdb.NewBoltStore(stableStoreFile)
+	if conf.LeaderLeaseTimeout > 0 {
+		raftConf.LeaderLeaseTimeout = conf.LeaderLeaseTimeout
+	}
+	if conf.MaxAppendEntries > 0 {
+		raftConf.MaxAppendEntries = conf.MaxAppendEntries
+	}
+	raftConf.BatchApplyCh = conf.BatchApply || conf.CoalesceWindow > 0
+	if conf.CoalesceWindow > 0 && conf.CoalesceMaxEntries > raftConf.MaxAppendEntries {
+		// coalesced requests are only committed together if raft can append all of them at once
+		raftConf.MaxAppendEntries = conf.CoalesceMaxEntries
+	}
+	if conf.SnapshotThreshold > 0 {
+		raftConf.SnapshotThreshold = conf.SnapshotThreshold
+	}
+	if conf.SnapshotInterval > 0 {
+		raftConf.SnapshotInterval = conf.SnapshotInterval
+	}
+	err = raft.ValidateConfig(raftConf)
 	if err != nil {
 		return nil, err
 	}
 
-	logStore, err := raft.NewLogCache(raftLogCacheSize, stableStore)
+	stableStoreFile := filepath.Join(dataDir, stableStoreFileName)
+	snapshotsDir := filepath.Join(dataDir, "snapshots")
+	if !conf.Durable {
+		if util.FileExists(stableStoreFile) {
+			err := os.Remove(stableStoreFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if util.IsDir(snapshotsDir) {
+			err := os.RemoveAll(snapshotsDir)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	stableStore, err := raftboltdb.New(raftboltdb.Options{
+		Path:        stableStoreFile,
+		BoltOptions: &bolt.Options{Timeout: boltOpenTimeout},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open raft store %s: %w", stableStoreFile, err)
+	}
+	// the store file stays locked until the store is closed, so it's closed on every error below,
+	// the created Node closes it when it's stopped
+	created := false
+	defer func() {
+		if !created {
+			_ = stableStore.Close()
+		}
+	}()
+
+	logStore, err := raft.NewLogCache(conf.LogCacheSize, stableStore)
 	if err != nil {
 		return nil, err
 	}
 
 	var snapshotStore raft.SnapshotStore
-	if !snapshotEnabled {
+	if !conf.SnapshotEnabled {
 		snapshotStore = raft.NewDiscardSnapshotStore()
 	} else {
-		// TODO: implement: snapshotStore = NewLogsOnlySnapshotStore(serializer)
-		return nil, errors.New("snapshots are not supported at the moment")
+		snapshotStore, err = raft.NewFileSnapshotStoreWithLogger(dataDir, conf.SnapshotRetain, raftConf.Logger.Named("snapshot"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// check whether we are restarting with a previous raft state
+	hasExistingState, err := raft.HasExistingState(logStore, stableStore, snapshotStore)
+	if err != nil {
+		return nil, err
 	}
 
+	// grpc credentials
+	dialOptions := []ggrpc.DialOption{ggrpc.WithInsecure()}
+	var serverOptions []ggrpc.ServerOption
+	var certs *certReloader
+	if conf.TLS != nil {
+		certs, err = newCertReloader(conf.TLS)
+		if err != nil {
+			return nil, err
+		}
+		creds := &reloadingCredentials{reloader: certs, serverName: conf.TLS.ServerName}
+		dialOptions = []ggrpc.DialOption{ggrpc.WithTransportCredentials(creds)}
+		serverOptions = append(serverOptions, ggrpc.Creds(creds))
+	}
+	serverOptions = append(serverOptions, conf.GrpcServerOptions...)
+
 	// grpc transport
-	grpcTransport := transport.New(raft.ServerAddress(addr), []ggrpc.DialOption{ggrpc.WithInsecure()})
+	grpcTransport := transport.New(raft.ServerAddress(advertiseAddr), dialOptions)
 
 	// init FSM
-	sm := fsm.NewRoutingFSM(services)
-	sm.Init(serializer)
+	sm := fsm.NewRoutingFSM(conf.Services)
+	sm.Init(conf.Serializer)
+
+	// metrics
+	var nodeMetrics *metrics
+	if conf.Metrics != nil {
+		nodeMetrics, err = newMetrics(*conf.Metrics, nodeId)
+		if err != nil {
+			return nil, err
+		}
+		sm.(*fsm.RoutingFSM).ObserveApply(nodeMetrics.observeFSMApply)
+	}
 
 	// memberlist config
-	mlConfig := memberlist.DefaultWANConfig()
-	mlConfig.BindPort = discoveryPort
-	mlConfig.Name = fmt.Sprintf("%s:%d", nodeId, raftPort)
+	mlConfig, err := conf.memberlistConfig()
+	if err != nil {
+		return nil, err
+	}
+	mlConfig.BindAddr = conf.BindAddress
+	mlConfig.BindPort = conf.DiscoveryPort
+	if conf.AdvertiseAddress != "" {
+		mlConfig.AdvertiseAddr = conf.AdvertiseAddress
+		mlConfig.AdvertisePort = conf.DiscoveryPort
+	}
+	mlConfig.Name = fmt.Sprintf("%s:%d", nodeId, conf.RaftPort)
+	mlConfig.Logger = conf.Logger
+	mlConfig.Keyring, err = newGossipKeyring(conf.GossipKeys)
+	if err != nil {
+		return nil, err
+	}
+	mlConfig.Delegate, err = newMetaDelegate(conf.Serializer, nodeMeta{Role: conf.Role})
+	if err != nil {
+		return nil, err
+	}
+	joinAllowlist, err := parseJoinAllowlist(conf.JoinAllowlist)
+	if err != nil {
+		return nil, err
+	}
 
 	// raft server
 	raftServer, err := raft.NewRaft(raftConf, sm, logStore, stableStore, snapshotStore, grpcTransport.Transport())
@@ -104,28 +285,93 @@ func NewNode(raftPort, discoveryPort int, dataDir string, services []fsm.FSMServ
 		return nil, err
 	}
 
-	// logging
-	logger := log.Default()
-	logger.SetPrefix("[EasyRaft] ")
+	// write coalescing
+	var writeCoalescer *coalescer
+	if conf.CoalesceWindow > 0 {
+		maxEntries := conf.CoalesceMaxEntries
+		if maxEntries <= 0 {
+			maxEntries = raftConf.MaxAppendEntries
+		}
+		writeCoalescer = newCoalescer(raftServer, conf.CoalesceWindow, maxEntries)
+	}
+
+	// autopilot
+	var serverAutopilot *autopilot
+	if conf.Autopilot != nil {
+		serverAutopilot = newAutopilot(*conf.Autopilot)
+	}
 
 	// initial stopped flag
 	var stopped uint32
 
-	return &Node{
+	node := &Node{
 		ID:               nodeId,
-		RaftPort:         raftPort,
+		RaftPort:         conf.RaftPort,
 		address:          addr,
 		dataDir:          dataDir,
 		Raft:             raftServer,
 		TransportManager: grpcTransport,
-		Serializer:       serializer,
-		DiscoveryPort:    discoveryPort,
-		DiscoveryMethod:  discoveryMethod,
+		Serializer:       conf.Serializer,
+		DiscoveryPort:    conf.DiscoveryPort,
+		DiscoveryMethod:  conf.DiscoveryMethod,
 		discoveryConfig:  mlConfig,
-		logger:           logger,
+		logger:           conf.Logger,
 		stopped:          &stopped,
-		snapshotEnabled:  snapshotEnabled,
-	}, nil
+		snapshotEnabled:  conf.SnapshotEnabled,
+		hasExistingState: hasExistingState,
+		config:           &conf,
+		dialOptions:      dialOptions,
+		serverOptions:    serverOptions,
+		certs:            certs,
+		joinAllowlist:    joinAllowlist,
+		fsm:              sm,
+		connPool:         newConnPool(dialOptions),
+		coalescer:        writeCoalescer,
+		autopilot:        serverAutopilot,
+		metrics:          nodeMetrics,
+		stableStore:      stableStore,
+	}
+	created = true
+	return node, nil
+}
+
+// loadNodeID returns the ID of the Node, in durable mode it is stored in (and loaded from) the data directory
+// so the Node keeps its identity between restarts. A raft store without a stored ID (e.g. left by a non-durable run)
+// belongs to an unknown identity, so it's refused instead of being continued under a new ID.
+func loadNodeID(dataDir string, configuredId string, durable bool) (string, error) {
+	if !durable {
+		if configuredId != "" {
+			return configuredId, nil
+		}
+		return uid.New(50), nil
+	}
+	nodeIdFile := filepath.Join(dataDir, nodeIdFileName)
+	if util.FileExists(nodeIdFile) {
+		content, err := ioutil.ReadFile(nodeIdFile)
+		if err != nil {
+			return "", err
+		}
+		nodeId := strings.TrimSpace(string(content))
+		if nodeId == "" {
+			return "", fmt.Errorf("empty node ID in %s", nodeIdFile)
+		}
+		if configuredId != "" && configuredId != nodeId {
+			return "", fmt.Errorf("configured node ID %q differs from the stored one %q", configuredId, nodeId)
+		}
+		return nodeId, nil
+	}
+	if stableStoreFile := filepath.Join(dataDir, stableStoreFileName); util.FileExists(stableStoreFile) {
+		return "", fmt.Errorf("raft store %s exists without a stored node ID in %s, remove it to start a new node", stableStoreFile, nodeIdFile)
+	}
+	nodeId := configuredId
+	if nodeId == "" {
+		nodeId = uid.New(50)
+	}
+	err := ioutil.WriteFile(nodeIdFile, []byte(nodeId), 0600)
+	if err != nil {
+		return "", err
+	}
+	return nodeId, nil
 }
 
 // Start starts the Node and returns a channel that indicates, that the node has been stopped properly
@@ -136,20 +382,52 @@ func (n *Node) Start() (chan interface{}, error) {
 		atomic.StoreUint32(n.stopped, 0)
 	}
 
-	// raft server
-	configuration := raft.Configuration{
-		Servers: []raft.Server{
-			{
-				ID:      raft.ServerID(n.ID),
-				Address: n.TransportManager.Transport().LocalAddr(),
-			},
-		},
-	}
-	f := n.Raft.BootstrapCluster(configuration)
-	err := f.Error()
+	// the raft port is claimed first, so the Node fails before starting anything when it's in use
+	grpcListen, err := net.Listen("tcp", n.address)
 	if err != nil {
 		return nil, err
 	}
+	started := false
+	defer func() {
+		if !started {
+			_ = grpcListen.Close()
+		}
+	}()
+
+	// raft server, bootstrap only when there is no previous state to continue from,
+	// nonvoters and witnesses wait until the leader adds them to the cluster
+	if n.hasExistingState {
+		n.logger.Println("Found existing raft state, skipping cluster bootstrap")
+	} else if n.config.Role != RoleVoter {
+		n.logger.Printf("Node role is %s, waiting to be added to the cluster\n", n.config.Role)
+	} else {
+		configuration := raft.Configuration{
+			Servers: []raft.Server{
+				{
+					ID:      raft.ServerID(n.ID),
+					Address: n.TransportManager.Transport().LocalAddr(),
+				},
+			},
+		}
+		f := n.Raft.BootstrapCluster(configuration)
+		err := f.Error()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// leadership and peer health tracking
+	n.observeRaft()
+
+	// write coalescing
+	if n.coalescer != nil {
+		go n.coalescer.run()
+	}
+
+	// autopilot
+	if n.autopilot != nil {
+		go n.runAutopilot()
+	}
 
 	// memberlist discovery
 	n.discoveryConfig.Events = n
@@ -158,13 +436,12 @@ func (n *Node) Start() (chan interface{}, error) {
 		return nil, err
 	}
 	n.mList = list
+	if err := n.metrics.registerNode(n); err != nil {
+		return nil, err
+	}
 
 	// grpc server
-	grpcListen, err := net.Listen("tcp", n.address)
-	if err != nil {
-		log.Fatal(err)
-	}
-	grpcServer := ggrpc.NewServer()
+	grpcServer := ggrpc.NewServer(n.serverOptions...)
 	n.GrpcServer = grpcServer
 
 	// register management services
@@ -174,7 +451,13 @@ func (n *Node) Start() (chan interface{}, error) {
 	clientGrpcServer := NewClientGrpcService(n)
 	grpc.RegisterRaftServer(grpcServer, clientGrpcServer)
 
+	// register health service
+	n.registerHealthServer(grpcServer)
+
 	// discovery method
+	if loggerSetter, ok := n.DiscoveryMethod.(discovery.LoggerSetter); ok {
+		loggerSetter.SetLogger(n.logger)
+	}
 	discoveryChan, err := n.DiscoveryMethod.Start(n.ID, n.RaftPort)
 	if err != nil {
 		return nil, err
@@ -184,10 +467,19 @@ func (n *Node) Start() (chan interface{}, error) {
 	// serve grpc
 	go func() {
 		if err := grpcServer.Serve(grpcListen); err != nil {
-			n.logger.Fatal(err)
+			n.logger.Printf("gRPC server failed: %q\n", err.Error())
 		}
 	}()
 
+	go n.runHealthChecks()
+
+	// serve http api
+	if n.config.HTTPAddress != "" {
+		if err := n.startHTTPServer(); err != nil {
+			return nil, err
+		}
+	}
+
 	// handle interruption
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGABRT, syscall.SIGKILL)
@@ -197,15 +489,29 @@ func (n *Node) Start() (chan interface{}, error) {
 	}()
 
 	n.logger.Printf("Node started on port %d and discovery port %d\n", n.RaftPort, n.DiscoveryPort)
-	n.stoppedCh = make(chan interface{})
+	n.stoppedCh = make(chan interface{}, 1)
+	started = true
 
 	return n.stoppedCh, nil
 }
 
-// Stop stops the node and notifies on stopped channel returned in Start
+// Stop stops the node and notifies on stopped channel returned in Start,
+// the leadership is transferred to another voter first if this Node is the leader
 func (n *Node) Stop() {
-	if atomic.LoadUint32(n.stopped) == 0 {
-		atomic.StoreUint32(n.stopped, 1)
+	n.stop(false)
+}
+
+// Leave stops the node like Stop, but it also removes the Node from the raft cluster,
+// it should be used when the Node leaves the cluster permanently
+func (n *Node) Leave() {
+	n.stop(true)
+}
+
+func (n *Node) stop(leave bool) {
+	if atomic.CompareAndSwapUint32(n.stopped, 0, 1) {
+		if n.healthServer != nil {
+			n.healthServer.Shutdown()
+		}
 		if n.snapshotEnabled {
 			n.logger.Println("Creating snapshot...")
 			err := n.Raft.Snapshot().Error()
@@ -213,6 +519,20 @@ func (n *Node) Stop() {
 				n.logger.Println("Failed to create snapshot!")
 			}
 		}
+		if n.IsLeader() {
+			n.logger.Println("Transferring leadership...")
+			err := n.TransferLeadership("")
+			if err != nil {
+				n.logger.Printf("Failed to transfer leadership: %q\n", err.Error())
+			}
+		}
+		if leave {
+			n.logger.Println("Leaving raft cluster...")
+			err := n.leaveCluster()
+			if err != nil {
+				n.logger.Printf("Failed to leave raft cluster: %q\n", err.Error())
+			}
+		}
 		n.logger.Println("Stopping Node...")
 		n.DiscoveryMethod.Stop()
 		err := n.mList.Leave(10 * time.Second)
@@ -224,22 +544,59 @@ func (n *Node) Stop() {
 			n.logger.Printf("Failed to shutdown discovery: %q\n", err.Error())
 		}
 		n.logger.Println("Discovery stopped")
+		n.stopObservingRaft()
+		if n.coalescer != nil {
+			n.coalescer.stop()
+		}
+		if n.autopilot != nil {
+			n.autopilot.stop()
+		}
+		n.stopHTTPServer()
+		n.metrics.unregisterNode()
 		err = n.Raft.Shutdown().Error()
 		if err != nil {
 			n.logger.Printf("Failed to shutdown Raft: %q\n", err.Error())
 		}
+		err = n.stableStore.Close()
+		if err != nil {
+			n.logger.Printf("Failed to close raft store: %q\n", err.Error())
+		}
 		n.logger.Println("Raft stopped")
-		n.GrpcServer.GracefulStop()
+		n.stopGrpcServer()
+		n.connPool.close()
 		n.logger.Println("Raft Server stopped")
 		n.logger.Println("Node Stopped!")
 		n.stoppedCh <- true
 	}
 }
 
+// stopGrpcServer stops the gRPC server gracefully, the raft streams opened by other nodes are never finished,
+// so the server is stopped forcibly when the pending requests aren't done within the timeout
+func (n *Node) stopGrpcServer() {
+	stopped := make(chan struct{})
+	go func() {
+		n.GrpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(grpcGracefulStopTimeout):
+		n.GrpcServer.Stop()
+	}
+}
+
+// Snapshot takes a snapshot of the state machine right away, snapshots must be enabled
+func (n *Node) Snapshot() error {
+	if !n.snapshotEnabled {
+		return errors.New("snapshots are disabled")
+	}
+	return n.Raft.Snapshot().Error()
+}
+
 // handleDiscoveredNodes handles the discovered Node additions
 func (n *Node) handleDiscoveredNodes(discoveryChan chan string) {
 	for peer := range discoveryChan {
-		detailsResp, err := GetPeerDetails(peer)
+		detailsResp, err := n.getPeerDetails(peer)
 		if err == nil {
 			serverId := detailsResp.ServerId
 			needToAddNode := true
@@ -254,7 +611,7 @@ func (n *Node) handleDiscoveredNodes(discoveryChan chan string) {
 				peerDiscoveryAddr := fmt.Sprintf("%s:%d", peerHost, detailsResp.DiscoveryPort)
 				_, err = n.mList.Join([]string{peerDiscoveryAddr})
 				if err != nil {
-					log.Printf("failed to join to cluster using discovery address: %s\n", peerDiscoveryAddr)
+					n.logger.Printf("Failed to join to cluster using discovery address %s: %q\n", peerDiscoveryAddr, err.Error())
 				}
 			}
 		}
@@ -267,24 +624,49 @@ func (n *Node) NotifyJoin(node *memberlist.Node) {
 	nameParts := strings.Split(node.Name, ":")
 	nodeId, nodePort := nameParts[0], nameParts[1]
 	nodeAddr := fmt.Sprintf("%s:%s", node.Addr, nodePort)
+	if nodeId == n.ID {
+		return
+	}
+	n.publishEvent(PeerDiscovered, nodeId, nodeAddr)
+	if !n.isJoinAllowed(node.Addr) {
+		n.logger.Printf("Node %s (%s) is not in the join allowlist, not adding it to the cluster\n", nodeId, nodeAddr)
+		return
+	}
 	if err := n.Raft.VerifyLeader().Error(); err == nil {
-		result := n.Raft.AddVoter(raft.ServerID(nodeId), raft.ServerAddress(nodeAddr), 0, 0)
-		if result.Error() != nil {
-			log.Println(result.Error().Error())
+		n.votersLock.Lock()
+		if n.shouldJoinAsVoter(nodeId, decodeNodeMeta(n.Serializer, node.Meta)) {
+			err = n.Raft.AddVoter(raft.ServerID(nodeId), raft.ServerAddress(nodeAddr), 0, 0).Error()
+		} else {
+			err = n.Raft.AddNonvoter(raft.ServerID(nodeId), raft.ServerAddress(nodeAddr), 0, 0).Error()
+		}
+		n.votersLock.Unlock()
+		if err != nil {
+			n.logger.Printf("Failed to add node %s (%s) to the cluster: %q\n", nodeId, nodeAddr, err.Error())
+		} else {
+			n.publishEvent(PeerJoinedRaft, nodeId, nodeAddr)
 		}
 	}
 }
 
 // NotifyLeave triggered when a Node becomes unavailable after a period of time
-// it will remove the unavailable Node from the Raft cluster
+// it will remove the unavailable Node from the Raft cluster and promote a nonvoter if a voter is missing
 func (n *Node) NotifyLeave(node *memberlist.Node) {
+	nameParts := strings.Split(node.Name, ":")
+	nodeId, nodePort := nameParts[0], nameParts[1]
+	if nodeId != n.ID {
+		address := fmt.Sprintf("%s:%s", node.Addr, nodePort)
+		n.publishEvent(PeerRemoved, nodeId, address)
+		// the pooled connection is created again if the peer comes back
+		n.connPool.invalidate(address, n.config.ForwardTimeout)
+	}
 	if n.DiscoveryMethod.SupportsNodeAutoRemoval() {
-		nodeId := strings.Split(node.Name, ":")[0]
 		if err := n.Raft.VerifyLeader().Error(); err == nil {
 			result := n.Raft.RemoveServer(raft.ServerID(nodeId), 0, 0)
 			if result.Error() != nil {
-				log.Println(result.Error().Error())
+				n.logger.Printf("Failed to remove node %s from the cluster: %q\n", nodeId, result.Error().Error())
 			}
+			// memberlist holds its node lock during the notification, so the members can be listed only later
+			go n.reconcileVoters()
 		}
 	}
 }
@@ -295,27 +677,93 @@ func (n *Node) NotifyUpdate(_ *memberlist.Node) {
 // RaftApply is used to apply any new logs to the raft cluster
 // this method does automatic forwarding to Leader Node
 func (n *Node) RaftApply(request interface{}, timeout time.Duration) (interface{}, error) {
-	payload, err := n.Serializer.Serialize(request)
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return n.RaftApplyContext(ctx, request)
+}
+
+// RaftApplyContext is used to apply any new logs to the raft cluster, it stops waiting for the result
+// when the context is done, the deadline of the context is propagated to the Leader Node
+// this method does automatic forwarding to Leader Node
+func (n *Node) RaftApplyContext(ctx context.Context, request interface{}) (interface{}, error) {
+	payload, err := n.fsm.EncodeCommand(request)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := n.Raft.VerifyLeader().Error(); err == nil {
-		result := n.Raft.Apply(payload, timeout)
-		if result.Error() != nil {
-			return nil, result.Error()
+	start := time.Now()
+	if n.Raft.State() == raft.Leader {
+		result, err := n.applyLocal(ctx, payload)
+		if err != raft.ErrNotLeader {
+			n.metrics.observeApply(false, start)
+			return result, err
 		}
-		switch result.Response().(type) {
-		case error:
-			return nil, result.Response().(error)
-		default:
-			return result.Response(), nil
+	}
+
+	response, err := applyOnLeader(ctx, n, payload)
+	n.metrics.observeApply(true, start)
+	if err != nil {
+		return nil, err
+	}
+	return n.fsm.DecodeResponse(request, response)
+}
+
+// applyLocal applies an already serialized request on this Node, the deadline of the context
+// is used as the raft enqueue timeout, errors returned by the FSM are turned into fsm.ApplicationError
+func (n *Node) applyLocal(ctx context.Context, payload []byte) (interface{}, error) {
+	if n.coalescer != nil {
+		future, err := n.coalescer.apply(ctx, payload)
+		if err != nil {
+			return nil, err
 		}
+		return waitApplied(ctx, future)
 	}
 
-	response, err := ApplyOnLeader(n, payload)
+	timeout, err := enqueueTimeout(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return response, nil
+	return waitApplied(ctx, n.Raft.Apply(payload, timeout))
+}
+
+// enqueueTimeout returns the raft enqueue timeout for the deadline of the context (0 means no timeout)
+func enqueueTimeout(ctx context.Context) (time.Duration, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, nil
+	}
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		return 0, context.DeadlineExceeded
+	}
+	return timeout, nil
+}
+
+// waitApplied waits until the log of the future is applied or the context is done
+// and returns the response of the FSM
+func waitApplied(ctx context.Context, future raft.ApplyFuture) (interface{}, error) {
+	done := make(chan struct{})
+	go func() {
+		_ = future.Error()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-done:
+	}
+
+	if future.Error() != nil {
+		return nil, future.Error()
+	}
+	switch response := future.Response().(type) {
+	case error:
+		return nil, fsm.ToApplicationError(response)
+	default:
+		return response, nil
+	}
 }
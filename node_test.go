@@ -0,0 +1,86 @@
+package easyraft
+
+import (
+	"github.com/ksrichard/easyraft/fsm"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStartFailsWhenRaftPortIsInUse(t *testing.T) {
+	config := newTestNodeConfig(t)
+	listener, err := net.Listen("tcp", config.raftAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	node, err := New(config.options(nil)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = node.Raft.Shutdown().Error()
+		_ = node.stableStore.Close()
+	}()
+	if _, err := node.Start(); err == nil {
+		t.Fatal("Start succeeded with the raft port in use")
+	}
+}
+
+func TestDurableNodeRestart(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "state replayed from the log"},
+		{name: "state restored from a snapshot", opts: []Option{WithSnapshots(0, 0, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestNodeConfig(t)
+			// every Node needs its own discovery method, so the options are created for each of them
+			options := func() []Option {
+				return config.options(nil, append([]Option{WithDurable(true)}, tt.opts...)...)
+			}
+
+			node := startTestNode(t, options()...)
+			waitUntil(t, "the node becomes the leader", node.IsLeader)
+			_, err := node.RaftApply(fsm.MapPutRequest{MapName: "test", Key: "key", Value: "value"}, time.Second)
+			if err != nil {
+				t.Fatalf("apply failed: %v", err)
+			}
+			node.Stop()
+
+			restarted := startTestNode(t, options()...)
+			if restarted.ID != node.ID {
+				t.Errorf("restarted node ID = %s, want %s", restarted.ID, node.ID)
+			}
+			waitUntil(t, "the restarted node becomes the leader", restarted.IsLeader)
+			value, err := restarted.Read(fsm.MapGetRequest{MapName: "test", Key: "key"}, time.Second)
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if value != "value" {
+				t.Errorf("value after restart = %v, want value", value)
+			}
+		})
+	}
+}
+
+func TestDurableNodeRefusesStoreWithoutNodeID(t *testing.T) {
+	config := newTestNodeConfig(t)
+	node, err := New(config.options(nil)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = node.Raft.Shutdown().Error()
+	_ = node.stableStore.Close()
+
+	durable, err := New(config.options(nil, WithDurable(true))...)
+	if err == nil {
+		_ = durable.Raft.Shutdown().Error()
+		_ = durable.stableStore.Close()
+		t.Fatal("durable node created on the raft store of a non-durable node")
+	}
+}
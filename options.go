@@ -0,0 +1,272 @@
+package easyraft
+
+import (
+	"github.com/ksrichard/easyraft/discovery"
+	"github.com/ksrichard/easyraft/fsm"
+	"github.com/ksrichard/easyraft/serializer"
+	ggrpc "google.golang.org/grpc"
+	"log"
+	"time"
+)
+
+// Option is used to customize the Config of a Node created with New
+type Option func(config *Config)
+
+// New returns an EasyRaft node configured with the given options on top of DefaultConfig
+func New(opts ...Option) (*Node, error) {
+	config := DefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	return NewNodeWithConfig(config)
+}
+
+// WithNodeID sets a fixed ID for the Node
+func WithNodeID(nodeID string) Option {
+	return func(config *Config) {
+		config.NodeID = nodeID
+	}
+}
+
+// WithRaftPort sets the port of the gRPC (raft + client services) server
+func WithRaftPort(port int) Option {
+	return func(config *Config) {
+		config.RaftPort = port
+	}
+}
+
+// WithDiscoveryPort sets the port used by the memberlist based discovery
+func WithDiscoveryPort(port int) Option {
+	return func(config *Config) {
+		config.DiscoveryPort = port
+	}
+}
+
+// WithBindAddress sets the address where the gRPC server and the discovery listens
+func WithBindAddress(address string) Option {
+	return func(config *Config) {
+		config.BindAddress = address
+	}
+}
+
+// WithAdvertiseAddress sets the address other nodes use to reach this Node
+func WithAdvertiseAddress(address string) Option {
+	return func(config *Config) {
+		config.AdvertiseAddress = address
+	}
+}
+
+// WithDataDir sets the directory where the raft state is stored
+func WithDataDir(dataDir string) Option {
+	return func(config *Config) {
+		config.DataDir = dataDir
+	}
+}
+
+// WithRole sets the role of the Node in the raft cluster
+func WithRole(role Role) Option {
+	return func(config *Config) {
+		config.Role = role
+	}
+}
+
+// WithMaxVoters sets the maximum number of voters in the cluster, further nodes join as nonvoters
+func WithMaxVoters(maxVoters int) Option {
+	return func(config *Config) {
+		config.MaxVoters = maxVoters
+	}
+}
+
+// WithAutopilot enables the server health tracking (and the dead server cleanup if it's configured) on the leader
+func WithAutopilot(autopilotConfig AutopilotConfig) Option {
+	return func(config *Config) {
+		config.Autopilot = &autopilotConfig
+	}
+}
+
+// WithServices sets the FSM services of the routing state machine
+func WithServices(services ...fsm.FSMService) Option {
+	return func(config *Config) {
+		config.Services = services
+	}
+}
+
+// WithSerializer sets the serializer used for raft log payloads
+func WithSerializer(ser serializer.Serializer) Option {
+	return func(config *Config) {
+		config.Serializer = ser
+	}
+}
+
+// WithDiscoveryMethod sets the discovery method used to find other nodes
+func WithDiscoveryMethod(discoveryMethod discovery.DiscoveryMethod) Option {
+	return func(config *Config) {
+		config.DiscoveryMethod = discoveryMethod
+	}
+}
+
+// WithSnapshots enables snapshots, zero values keep the defaults of retain, threshold and interval
+func WithSnapshots(retain int, threshold uint64, interval time.Duration) Option {
+	return func(config *Config) {
+		config.SnapshotEnabled = true
+		config.SnapshotRetain = retain
+		config.SnapshotThreshold = threshold
+		config.SnapshotInterval = interval
+	}
+}
+
+// WithDurable enables/disables keeping the node ID and raft state between restarts
+func WithDurable(durable bool) Option {
+	return func(config *Config) {
+		config.Durable = durable
+	}
+}
+
+// WithHeartbeatTimeout sets the raft heartbeat timeout
+func WithHeartbeatTimeout(timeout time.Duration) Option {
+	return func(config *Config) {
+		config.HeartbeatTimeout = timeout
+	}
+}
+
+// WithElectionTimeout sets the raft election timeout
+func WithElectionTimeout(timeout time.Duration) Option {
+	return func(config *Config) {
+		config.ElectionTimeout = timeout
+	}
+}
+
+// WithCommitTimeout sets the raft commit timeout
+func WithCommitTimeout(timeout time.Duration) Option {
+	return func(config *Config) {
+		config.CommitTimeout = timeout
+	}
+}
+
+// WithLeaderLeaseTimeout sets the raft leader lease timeout
+func WithLeaderLeaseTimeout(timeout time.Duration) Option {
+	return func(config *Config) {
+		config.LeaderLeaseTimeout = timeout
+	}
+}
+
+// WithBatchApply enables committing concurrently applied logs together, up to maxAppendEntries logs at once
+// (0 keeps the raft default)
+func WithBatchApply(maxAppendEntries int) Option {
+	return func(config *Config) {
+		config.BatchApply = true
+		config.MaxAppendEntries = maxAppendEntries
+	}
+}
+
+// WithoutBatchApply disables committing concurrently applied logs together (the default),
+// so the raft enqueue timeout is guaranteed
+func WithoutBatchApply() Option {
+	return func(config *Config) {
+		config.BatchApply = false
+	}
+}
+
+// WithWriteCoalescing enables committing concurrent RaftApply calls together on the leader, the requests are
+// collected for the given window or until maxEntries requests arrive (0 means MaxAppendEntries)
+func WithWriteCoalescing(window time.Duration, maxEntries int) Option {
+	return func(config *Config) {
+		config.CoalesceWindow = window
+		config.CoalesceMaxEntries = maxEntries
+	}
+}
+
+// WithLogCacheSize sets the number of raft logs cached in memory
+func WithLogCacheSize(size int) Option {
+	return func(config *Config) {
+		config.LogCacheSize = size
+	}
+}
+
+// WithLogLevel sets the log level of raft
+func WithLogLevel(level string) Option {
+	return func(config *Config) {
+		config.LogLevel = level
+	}
+}
+
+// WithMemberlistProfile sets the memberlist configuration profile used for discovery
+func WithMemberlistProfile(profile MemberlistProfile) Option {
+	return func(config *Config) {
+		config.MemberlistProfile = profile
+	}
+}
+
+// WithQueryTimeout sets the maximum time a Query waits for the state machine to catch up
+func WithQueryTimeout(timeout time.Duration) Option {
+	return func(config *Config) {
+		config.QueryTimeout = timeout
+	}
+}
+
+// WithForwardTimeout sets the deadline of the calls forwarded to other nodes when the caller has no timeout
+func WithForwardTimeout(timeout time.Duration) Option {
+	return func(config *Config) {
+		config.ForwardTimeout = timeout
+	}
+}
+
+// WithHTTPAddress enables the HTTP admin and status API listening on the given address
+func WithHTTPAddress(address string) Option {
+	return func(config *Config) {
+		config.HTTPAddress = address
+	}
+}
+
+// WithMetrics enables the Prometheus metrics of the Node
+func WithMetrics(metricsConfig MetricsConfig) Option {
+	return func(config *Config) {
+		config.Metrics = &metricsConfig
+	}
+}
+
+// WithReadyMaxLag sets the maximum number of committed logs a Ready Node may not have applied yet
+func WithReadyMaxLag(lag uint64) Option {
+	return func(config *Config) {
+		config.ReadyMaxLag = lag
+	}
+}
+
+// WithTLS enables mutual TLS between the nodes using the given certificate, key and CA bundle files
+func WithTLS(certFile, keyFile, caFile string) Option {
+	return func(config *Config) {
+		config.TLS = &TLSConfig{
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			CAFile:   caFile,
+		}
+	}
+}
+
+// WithGossipKeys enables encrypted and authenticated discovery gossip, the first key is the primary one
+func WithGossipKeys(keys ...[]byte) Option {
+	return func(config *Config) {
+		config.GossipKeys = keys
+	}
+}
+
+// WithJoinAllowlist sets the IP addresses and CIDR ranges of nodes allowed to join the raft cluster
+func WithJoinAllowlist(entries ...string) Option {
+	return func(config *Config) {
+		config.JoinAllowlist = entries
+	}
+}
+
+// WithGrpcServerOptions sets additional options for the gRPC server of the Node
+func WithGrpcServerOptions(opts ...ggrpc.ServerOption) Option {
+	return func(config *Config) {
+		config.GrpcServerOptions = append(config.GrpcServerOptions, opts...)
+	}
+}
+
+// WithLogger sets the logger of the Node
+func WithLogger(logger *log.Logger) Option {
+	return func(config *Config) {
+		config.Logger = logger
+	}
+}
@@ -0,0 +1,61 @@
+package easyraft
+
+import (
+	ggrpc "google.golang.org/grpc"
+	"sync"
+	"time"
+)
+
+// connPool keeps one reusable gRPC client connection per peer address,
+// so forwarding calls don't need to do a new TCP/HTTP2 (and TLS) handshake for every request
+type connPool struct {
+	sync.Mutex
+	dialOptions []ggrpc.DialOption
+	conns       map[string]*ggrpc.ClientConn
+}
+
+func newConnPool(dialOptions []ggrpc.DialOption) *connPool {
+	return &connPool{
+		dialOptions: dialOptions,
+		conns:       map[string]*ggrpc.ClientConn{},
+	}
+}
+
+// get returns the pooled connection of the address, the connection is created without blocking
+// if it doesn't exist yet, calls on it are failing fast or when their deadline is exceeded if the peer is down
+func (p *connPool) get(address string) (*ggrpc.ClientConn, error) {
+	p.Lock()
+	defer p.Unlock()
+	if conn, found := p.conns[address]; found {
+		return conn, nil
+	}
+	conn, err := ggrpc.Dial(address, p.dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[address] = conn
+	return conn, nil
+}
+
+// invalidate removes the pooled connection of the address, the connection is closed after the given drain time,
+// so the calls which are still in flight on it (e.g. forwarded during a leadership change) can finish
+func (p *connPool) invalidate(address string, drain time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+	if conn, found := p.conns[address]; found {
+		delete(p.conns, address)
+		time.AfterFunc(drain, func() {
+			_ = conn.Close()
+		})
+	}
+}
+
+// close closes all the pooled connections
+func (p *connPool) close() {
+	p.Lock()
+	defer p.Unlock()
+	for address, conn := range p.conns {
+		_ = conn.Close()
+		delete(p.conns, address)
+	}
+}
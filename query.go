@@ -0,0 +1,137 @@
+package easyraft
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/raft"
+	"github.com/ksrichard/easyraft/fsm"
+	"sync/atomic"
+	"time"
+)
+
+// ReadConsistency is the consistency level of a Query
+type ReadConsistency int
+
+const (
+	// ReadStale serves the query from the local state machine of any Node, the result can be stale
+	ReadStale ReadConsistency = iota
+
+	// ReadLease serves the query on the Leader Node without confirming its leadership with a quorum, relying on
+	// the leader lease, the result is stale only if the leader has been deposed but it doesn't know it yet
+	ReadLease
+
+	// ReadLinearizable confirms the leadership with a quorum before serving the query on the Leader Node
+	ReadLinearizable
+)
+
+func (c ReadConsistency) String() string {
+	switch c {
+	case ReadStale:
+		return "stale"
+	case ReadLease:
+		return "lease"
+	case ReadLinearizable:
+		return "linearizable"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(c))
+	}
+}
+
+// ErrReadTimeout is returned when the state machine doesn't catch up with the read index in time
+var ErrReadTimeout = errors.New("timed out waiting for the state machine to catch up")
+
+// Read serves a read-only request (see fsm.QueryService) with linearizable consistency without appending it
+// to the raft log, this method does automatic forwarding to Leader Node
+func (n *Node) Read(request interface{}, timeout time.Duration) (interface{}, error) {
+	result, _, err := n.query(request, ReadLinearizable, timeout)
+	return result, err
+}
+
+// Query serves a read-only request (see fsm.QueryService) with the given consistency level without appending it
+// to the raft log, it returns the result and the applied index of the state machine which served the query.
+// Lease and linearizable queries are forwarded to the Leader Node automatically.
+func (n *Node) Query(request interface{}, consistency ReadConsistency) (interface{}, uint64, error) {
+	return n.query(request, consistency, n.config.QueryTimeout)
+}
+
+func (n *Node) query(request interface{}, consistency ReadConsistency, timeout time.Duration) (interface{}, uint64, error) {
+	payload, err := n.fsm.EncodeQuery(request)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// witnesses don't serve reads, not even stale ones
+	if (consistency == ReadStale && n.config.Role != RoleWitness) || n.Raft.State() == raft.Leader {
+		result, index, err := n.localQuery(payload, consistency, timeout)
+		if err != raft.ErrNotLeader && err != raft.ErrLeadershipLost {
+			return result, index, err
+		}
+	}
+
+	response, index, err := queryOnLeader(n, payload, consistency, n.forwardTimeout(timeout))
+	if err != nil {
+		return nil, 0, err
+	}
+	result, err := n.fsm.DecodeResponse(request, response)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, index, nil
+}
+
+// localQuery serves an encoded read-only request from the local state machine with the given consistency level,
+// lease and linearizable queries are returning raft.ErrNotLeader if this Node is not the leader
+func (n *Node) localQuery(payload []byte, consistency ReadConsistency, timeout time.Duration) (interface{}, uint64, error) {
+	switch consistency {
+	case ReadStale:
+	case ReadLease:
+		if n.Raft.State() != raft.Leader {
+			return nil, 0, raft.ErrNotLeader
+		}
+		if atomic.LoadUint32(&n.leaseReady) == 0 {
+			// the previous leaders' logs might not be applied yet
+			return n.localQuery(payload, ReadLinearizable, timeout)
+		}
+	case ReadLinearizable:
+		readIndex := n.Raft.LastIndex()
+		err := n.Raft.VerifyLeader().Error()
+		if err != nil {
+			return nil, 0, err
+		}
+		if atomic.LoadUint32(&n.leaseReady) == 0 {
+			// raft reports the logs of the previous leaders as applied once they are handed over to the state machine,
+			// until the leadership barrier is done the barrier waits until the state machine actually applied them
+			err = n.Raft.Barrier(timeout).Error()
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		err = n.waitForApplied(readIndex, timeout)
+		if err != nil {
+			return nil, 0, err
+		}
+	default:
+		return nil, 0, fmt.Errorf("unknown read consistency: %s", consistency)
+	}
+	index := n.Raft.AppliedIndex()
+	result := n.fsm.Query(payload)
+	if err, ok := result.(error); ok {
+		return nil, 0, fsm.ToApplicationError(err)
+	}
+	return result, index, nil
+}
+
+// waitForApplied waits until the state machine applied every log up to the given index, 0 timeout means no timeout
+func (n *Node) waitForApplied(index uint64, timeout time.Duration) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for n.Raft.AppliedIndex() < index {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return ErrReadTimeout
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
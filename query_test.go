@@ -0,0 +1,78 @@
+package easyraft
+
+import (
+	"fmt"
+	"github.com/ksrichard/easyraft/fsm"
+	"testing"
+	"time"
+)
+
+func TestReadsObserveAcknowledgedWrites(t *testing.T) {
+	nodes := startTestCluster(t, 3)
+	leader := leaderOf(t, nodes)
+	var follower *Node
+	for _, node := range nodes {
+		if node != leader {
+			follower = node
+			break
+		}
+	}
+
+	tests := []struct {
+		name        string
+		consistency ReadConsistency
+		writer      *Node
+		reader      *Node
+	}{
+		{name: "lease read on the leader", consistency: ReadLease, writer: follower, reader: leader},
+		{name: "lease read forwarded by a follower", consistency: ReadLease, writer: leader, reader: follower},
+		{name: "linearizable read on the leader", consistency: ReadLinearizable, writer: follower, reader: leader},
+		{name: "linearizable read forwarded by a follower", consistency: ReadLinearizable, writer: leader, reader: follower},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				want := fmt.Sprint(i)
+				_, err := tt.writer.RaftApply(fsm.MapPutRequest{MapName: "test", Key: tt.name, Value: want}, time.Second)
+				if err != nil {
+					t.Fatalf("write %d failed: %v", i, err)
+				}
+				got, _, err := tt.reader.Query(fsm.MapGetRequest{MapName: "test", Key: tt.name}, tt.consistency)
+				if err != nil {
+					t.Fatalf("read %d failed: %v", i, err)
+				}
+				if got != want {
+					t.Fatalf("read %d = %v, want %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadsAfterLeaderChange(t *testing.T) {
+	for _, consistency := range []ReadConsistency{ReadLease, ReadLinearizable} {
+		t.Run(consistency.String(), func(t *testing.T) {
+			nodes := startTestCluster(t, 3)
+			leader := leaderOf(t, nodes)
+			for i := 0; i < 50; i++ {
+				_, err := leader.RaftApply(fsm.MapPutRequest{MapName: "test", Key: fmt.Sprint(i), Value: i}, time.Second)
+				if err != nil {
+					t.Fatalf("write %d failed: %v", i, err)
+				}
+			}
+			if err := leader.TransferLeadership(""); err != nil {
+				t.Fatalf("leadership transfer failed: %v", err)
+			}
+			newLeader := leaderOf(t, nodes)
+
+			// the logs of the previous leader are served right away, without waiting for the leadership barrier
+			got, _, err := newLeader.Query(fsm.MapGetRequest{MapName: "test", Key: "49"}, consistency)
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if fmt.Sprint(got) != "49" {
+				t.Errorf("read on the new leader = %v, want 49", got)
+			}
+		})
+	}
+}
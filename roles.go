@@ -0,0 +1,97 @@
+package easyraft
+
+import (
+	"errors"
+	"github.com/hashicorp/memberlist"
+	"github.com/ksrichard/easyraft/serializer"
+)
+
+// Role is the role of a Node in the raft cluster
+type Role string
+
+const (
+	// RoleVoter nodes take part in the elections and in the write quorum (default)
+	RoleVoter Role = "voter"
+
+	// RoleNonvoter nodes (read replicas) receive the raft log and serve stale reads,
+	// but they don't count in the quorum, so they don't affect write latency or availability
+	RoleNonvoter Role = "nonvoter"
+
+	// RoleWitness nodes count in the quorum, but never serve reads and hand off the leadership right after winning
+	// an election. The raft log is still replicated to them, as hashicorp/raft has no log-less members.
+	RoleWitness Role = "witness"
+)
+
+// validate checks whether the role is known
+func (r Role) validate() error {
+	switch r {
+	case RoleVoter, RoleNonvoter, RoleWitness:
+		return nil
+	default:
+		return errors.New("unknown node role: " + string(r))
+	}
+}
+
+// isVoter returns true when the role takes part in the elections
+func (r Role) isVoter() bool {
+	return r != RoleNonvoter
+}
+
+// nodeMeta is the metadata advertised by the Node via memberlist
+type nodeMeta struct {
+	Role Role
+}
+
+// metaDelegate is the memberlist delegate advertising the metadata of the Node,
+// apart from the metadata it doesn't use the delegate features
+type metaDelegate struct {
+	meta []byte
+}
+
+// newMetaDelegate returns a memberlist delegate advertising the given metadata
+func newMetaDelegate(ser serializer.Serializer, meta nodeMeta) (*metaDelegate, error) {
+	data, err := ser.Serialize(meta)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > memberlist.MetaMaxSize {
+		return nil, errors.New("node metadata is too large")
+	}
+	return &metaDelegate{meta: data}, nil
+}
+
+func (d *metaDelegate) NodeMeta(limit int) []byte {
+	if len(d.meta) > limit {
+		return nil
+	}
+	return d.meta
+}
+
+func (d *metaDelegate) NotifyMsg([]byte) {
+}
+
+func (d *metaDelegate) GetBroadcasts(int, int) [][]byte {
+	return nil
+}
+
+func (d *metaDelegate) LocalState(bool) []byte {
+	return nil
+}
+
+func (d *metaDelegate) MergeRemoteState([]byte, bool) {
+}
+
+// decodeNodeMeta decodes the metadata advertised by another Node,
+// nodes without (valid) metadata are considered as voters
+func decodeNodeMeta(ser serializer.Serializer, data []byte) nodeMeta {
+	meta := nodeMeta{Role: RoleVoter}
+	if len(data) == 0 {
+		return meta
+	}
+	var decoded nodeMeta
+	err := serializer.DeserializeInto(ser, data, &decoded)
+	if err != nil || decoded.Role.validate() != nil {
+		return meta
+	}
+	return decoded
+}
@@ -20,3 +20,7 @@ func (s *ConverterService) Deserialize(data []byte) (interface{}, error) {
 	err := msgpack.Unmarshal(data, &result)
 	return result, err
 }
+
+func (s *ConverterService) DeserializeInto(data []byte, target interface{}) error {
+	return msgpack.Unmarshal(data, target)
+}
@@ -1,5 +1,7 @@
 package serializer
 
+import "github.com/mitchellh/mapstructure"
+
 // Serializer interface is to provide serialize and deserialize methods for EasyRaft Node
 type Serializer interface {
 	// Serialize is used to serialize and data to a []byte
@@ -8,3 +10,25 @@ type Serializer interface {
 	// Deserialize is used to deserialize []byte to interface{}
 	Deserialize(data []byte) (interface{}, error)
 }
+
+// TypedSerializer is an optional extension of Serializer for serializers which are able to deserialize
+// directly into a concrete Go type
+type TypedSerializer interface {
+	Serializer
+
+	// DeserializeInto is used to deserialize []byte into the value pointed to by target
+	DeserializeInto(data []byte, target interface{}) error
+}
+
+// DeserializeInto deserializes data into the value pointed to by target using the given Serializer,
+// if it's not a TypedSerializer the generic deserialized value is decoded into target
+func DeserializeInto(ser Serializer, data []byte, target interface{}) error {
+	if typedSer, ok := ser.(TypedSerializer); ok {
+		return typedSer.DeserializeInto(data, target)
+	}
+	result, err := ser.Deserialize(data)
+	if err != nil {
+		return err
+	}
+	return mapstructure.Decode(result, target)
+}
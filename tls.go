@@ -0,0 +1,236 @@
+package easyraft
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig holds the certificates used for mutual TLS between the nodes,
+// the files are watched and reloaded when they change, so certificates can be rotated without a restart
+type TLSConfig struct {
+	// CertFile is the PEM encoded certificate of the Node, used both as server and as client certificate
+	CertFile string
+
+	// KeyFile is the PEM encoded private key of CertFile
+	KeyFile string
+
+	// CAFile is the PEM encoded CA bundle used to verify the certificates of other nodes
+	CAFile string
+
+	// ServerName overrides the expected server name of the other nodes (default: host of the dialed address)
+	ServerName string
+}
+
+// certReloadCheckInterval is the minimum time between two checks of the certificate files
+const certReloadCheckInterval = time.Second
+
+// certReloader loads the certificates of a TLSConfig and reloads them when the files change
+type certReloader struct {
+	sync.Mutex
+	config    *TLSConfig
+	cert      *tls.Certificate
+	caPool    *x509.CertPool
+	modTimes  []time.Time
+	lastCheck time.Time
+}
+
+func newCertReloader(config *TLSConfig) (*certReloader, error) {
+	if config.CertFile == "" || config.KeyFile == "" || config.CAFile == "" {
+		return nil, errors.New("certificate, key and CA files must be set for TLS")
+	}
+	r := &certReloader{config: config}
+	err := r.reload()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload loads the certificate, the key and the CA bundle from disk
+func (r *certReloader) reload() error {
+	modTimes, err := r.fileModTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return err
+	}
+	caData, err := ioutil.ReadFile(r.config.CAFile)
+	if err != nil {
+		return err
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caData) {
+		return fmt.Errorf("no valid CA certificate found in %s", r.config.CAFile)
+	}
+	r.cert, r.caPool, r.modTimes = &cert, caPool, modTimes
+	return nil
+}
+
+func (r *certReloader) fileModTimes() ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, file := range []string{r.config.CertFile, r.config.KeyFile, r.config.CAFile} {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, fi.ModTime())
+	}
+	return modTimes, nil
+}
+
+// current returns the actual certificate and CA pool, reloading them first if any of the files changed,
+// if the reload fails the previously loaded certificates are kept
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.Lock()
+	defer r.Unlock()
+	if time.Since(r.lastCheck) < certReloadCheckInterval {
+		return r.cert, r.caPool
+	}
+	r.lastCheck = time.Now()
+	modTimes, err := r.fileModTimes()
+	if err != nil {
+		return r.cert, r.caPool
+	}
+	for i, modTime := range modTimes {
+		if !modTime.Equal(r.modTimes[i]) {
+			_ = r.reload()
+			break
+		}
+	}
+	return r.cert, r.caPool
+}
+
+func (r *certReloader) serverConfig() *tls.Config {
+	cert, caPool := r.current()
+	return &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		ClientCAs:    caPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+func (r *certReloader) clientConfig(serverName string) *tls.Config {
+	cert, caPool := r.current()
+	return &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		RootCAs:      caPool,
+		ServerName:   serverName,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// reloadingCredentials are gRPC transport credentials doing mutual TLS handshakes
+// with the latest certificates of a certReloader
+type reloadingCredentials struct {
+	sync.Mutex
+	reloader   *certReloader
+	serverName string
+	// securityVersion is the TLS version negotiated by the last handshake
+	securityVersion string
+}
+
+// newTLSCredentials returns gRPC transport credentials for the given TLSConfig
+func newTLSCredentials(config *TLSConfig) (credentials.TransportCredentials, error) {
+	reloader, err := newCertReloader(config)
+	if err != nil {
+		return nil, err
+	}
+	return &reloadingCredentials{reloader: reloader, serverName: config.ServerName}, nil
+}
+
+// TLSDialOption returns a gRPC dial option for clients (e.g. tools) connecting to nodes with mutual TLS enabled
+func TLSDialOption(config *TLSConfig) (grpc.DialOption, error) {
+	creds, err := newTLSCredentials(config)
+	if err != nil {
+		return nil, err
+	}
+	return grpc.WithTransportCredentials(creds), nil
+}
+
+func (c *reloadingCredentials) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, authInfo, err := credentials.NewTLS(c.reloader.clientConfig(c.getServerName())).ClientHandshake(ctx, authority, rawConn)
+	if err == nil {
+		c.setSecurityVersion(authInfo)
+	}
+	return conn, authInfo, err
+}
+
+func (c *reloadingCredentials) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, authInfo, err := credentials.NewTLS(c.reloader.serverConfig()).ServerHandshake(rawConn)
+	if err == nil {
+		c.setSecurityVersion(authInfo)
+	}
+	return conn, authInfo, err
+}
+
+// Info reports the TLS version negotiated by the last handshake, or the minimum supported version before the first one
+func (c *reloadingCredentials) Info() credentials.ProtocolInfo {
+	c.Lock()
+	defer c.Unlock()
+	securityVersion := c.securityVersion
+	if securityVersion == "" {
+		securityVersion = tlsVersionName(tls.VersionTLS12)
+	}
+	return credentials.ProtocolInfo{
+		SecurityProtocol: "tls",
+		SecurityVersion:  securityVersion,
+		ServerName:       c.serverName,
+	}
+}
+
+func (c *reloadingCredentials) Clone() credentials.TransportCredentials {
+	c.Lock()
+	defer c.Unlock()
+	return &reloadingCredentials{reloader: c.reloader, serverName: c.serverName, securityVersion: c.securityVersion}
+}
+
+func (c *reloadingCredentials) OverrideServerName(serverName string) error {
+	c.Lock()
+	defer c.Unlock()
+	c.serverName = serverName
+	return nil
+}
+
+func (c *reloadingCredentials) getServerName() string {
+	c.Lock()
+	defer c.Unlock()
+	return c.serverName
+}
+
+func (c *reloadingCredentials) setSecurityVersion(authInfo credentials.AuthInfo) {
+	tlsInfo, ok := authInfo.(credentials.TLSInfo)
+	if !ok {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.securityVersion = tlsVersionName(tlsInfo.State.Version)
+}
+
+// tlsVersionName returns the version of a tls.ConnectionState in the format of credentials.ProtocolInfo
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "1.0"
+	case tls.VersionTLS11:
+		return "1.1"
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
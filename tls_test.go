@@ -0,0 +1,128 @@
+package easyraft
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeTestCertificates writes a CA and a certificate signed by it for 127.0.0.1 into dir
+func writeTestCertificates(t *testing.T, dir string) *TLSConfig {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &TLSConfig{
+		CertFile: filepath.Join(dir, "node.pem"),
+		KeyFile:  filepath.Join(dir, "node-key.pem"),
+		CAFile:   filepath.Join(dir, "ca.pem"),
+	}
+	files := map[string]*pem.Block{
+		config.CertFile: {Type: "CERTIFICATE", Bytes: certDER},
+		config.KeyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+		config.CAFile:   {Type: "CERTIFICATE", Bytes: caDER},
+	}
+	for file, block := range files {
+		if err := ioutil.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return config
+}
+
+func TestTLSCredentialsReportNegotiatedVersion(t *testing.T) {
+	config := writeTestCertificates(t, t.TempDir())
+	serverCreds, err := newTLSCredentials(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCreds, err := newTLSCredentials(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version := clientCreds.Info().SecurityVersion; version != "1.2" {
+		t.Errorf("version before the handshake = %s, want 1.2", version)
+	}
+	if err := clientCreds.OverrideServerName("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	var wg sync.WaitGroup
+	var serverErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _, serverErr = serverCreds.ServerHandshake(serverConn)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _, err = clientCreds.ClientHandshake(ctx, "127.0.0.1", clientConn)
+	if err != nil {
+		t.Fatalf("client handshake failed: %v", err)
+	}
+	wg.Wait()
+	if serverErr != nil {
+		t.Fatalf("server handshake failed: %v", serverErr)
+	}
+
+	// both sides support TLS 1.3, so it's negotiated
+	if info := clientCreds.Info(); info.SecurityVersion != "1.3" || info.ServerName != "127.0.0.1" {
+		t.Errorf("client info = %+v, want version 1.3 and server name 127.0.0.1", info)
+	}
+	if version := serverCreds.Info().SecurityVersion; version != "1.3" {
+		t.Errorf("server version = %s, want 1.3", version)
+	}
+	if version := clientCreds.Clone().Info().SecurityVersion; version != "1.3" {
+		t.Errorf("cloned version = %s, want 1.3", version)
+	}
+}
@@ -0,0 +1,119 @@
+package easyraft
+
+import (
+	"github.com/hashicorp/raft"
+	"sort"
+	"strings"
+)
+
+// shouldJoinAsVoter returns true when a discovered Node with the given metadata has to be added as a voter,
+// voter-eligible nodes join as nonvoters when the cluster already has Config.MaxVoters voters
+func (n *Node) shouldJoinAsVoter(nodeId string, meta nodeMeta) bool {
+	if !meta.Role.isVoter() {
+		return false
+	}
+	if n.config.MaxVoters <= 0 {
+		return true
+	}
+	voters := 0
+	for _, server := range n.Raft.GetConfiguration().Configuration().Servers {
+		if server.Suffrage != raft.Voter {
+			continue
+		}
+		if server.ID == raft.ServerID(nodeId) {
+			return true
+		}
+		voters++
+	}
+	return voters < n.config.MaxVoters
+}
+
+// reconcileVoters keeps the number of voters at Config.MaxVoters on the leader, voter-eligible nonvoters
+// are promoted when voters are missing, and voters are demoted when there are too many of them
+// (unreachable voters first, the leader is never demoted)
+func (n *Node) reconcileVoters() {
+	if n.config.MaxVoters <= 0 || n.Raft.State() != raft.Leader {
+		return
+	}
+	n.votersLock.Lock()
+	defer n.votersLock.Unlock()
+
+	future := n.Raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		n.logger.Printf("Failed to get raft configuration: %q\n", err.Error())
+		return
+	}
+	alive := n.aliveMembers()
+	promote, demote := voterChanges(future.Configuration().Servers, alive, raft.ServerID(n.ID), n.config.MaxVoters)
+	for _, server := range promote {
+		err := n.Raft.AddVoter(server.ID, server.Address, 0, 0).Error()
+		if err != nil {
+			n.logger.Printf("Failed to promote %s to voter: %q\n", server.ID, err.Error())
+			continue
+		}
+		n.logger.Printf("Promoted %s to voter\n", server.ID)
+	}
+	for _, server := range demote {
+		err := n.Raft.DemoteVoter(server.ID, 0, 0).Error()
+		if err != nil {
+			n.logger.Printf("Failed to demote voter %s: %q\n", server.ID, err.Error())
+			continue
+		}
+		n.logger.Printf("Demoted voter %s to nonvoter\n", server.ID)
+	}
+}
+
+// voterChanges returns the nonvoters to promote and the voters to demote to have maxVoters voters,
+// only alive voter-eligible nonvoters are promoted (alive ones first, by ID), the voters are demoted
+// in the reverse order, so the unreachable ones go first, self is never demoted
+func voterChanges(servers []raft.Server, alive map[string]nodeMeta, self raft.ServerID, maxVoters int) (promote []raft.Server, demote []raft.Server) {
+	var voters, candidates []raft.Server
+	for _, server := range servers {
+		switch server.Suffrage {
+		case raft.Voter:
+			voters = append(voters, server)
+		case raft.Nonvoter:
+			if meta, ok := alive[string(server.ID)]; ok && meta.Role.isVoter() {
+				candidates = append(candidates, server)
+			}
+		}
+	}
+
+	sortServers(candidates, alive)
+	for i := 0; len(voters)+len(promote) < maxVoters && i < len(candidates); i++ {
+		promote = append(promote, candidates[i])
+	}
+
+	sortServers(voters, alive)
+	for i := len(voters) - 1; len(voters)-len(demote) > maxVoters && i >= 0; i-- {
+		if voters[i].ID != self {
+			demote = append(demote, voters[i])
+		}
+	}
+	return promote, demote
+}
+
+// aliveMembers returns the metadata of the alive discovery members by node ID
+func (n *Node) aliveMembers() map[string]nodeMeta {
+	members := map[string]nodeMeta{}
+	if n.mList == nil {
+		return members
+	}
+	for _, member := range n.mList.Members() {
+		nodeId := strings.Split(member.Name, ":")[0]
+		members[nodeId] = decodeNodeMeta(n.Serializer, member.Meta)
+	}
+	return members
+}
+
+// sortServers sorts the servers by ID, the alive ones first
+func sortServers(servers []raft.Server, alive map[string]nodeMeta) {
+	sort.SliceStable(servers, func(i, j int) bool {
+		_, iAlive := alive[string(servers[i].ID)]
+		_, jAlive := alive[string(servers[j].ID)]
+		if iAlive != jAlive {
+			return iAlive
+		}
+		return servers[i].ID < servers[j].ID
+	})
+}
@@ -0,0 +1,106 @@
+package easyraft
+
+import (
+	"github.com/hashicorp/raft"
+	"reflect"
+	"testing"
+)
+
+func TestVoterChanges(t *testing.T) {
+	voter := func(id string) raft.Server {
+		return raft.Server{ID: raft.ServerID(id), Suffrage: raft.Voter}
+	}
+	nonvoter := func(id string) raft.Server {
+		return raft.Server{ID: raft.ServerID(id), Suffrage: raft.Nonvoter}
+	}
+
+	tests := []struct {
+		name        string
+		servers     []raft.Server
+		alive       map[string]Role
+		maxVoters   int
+		wantPromote []string
+		wantDemote  []string
+	}{
+		{
+			name:      "voters at the limit",
+			servers:   []raft.Server{voter("self"), voter("b"), nonvoter("c")},
+			alive:     map[string]Role{"self": RoleVoter, "b": RoleVoter, "c": RoleVoter},
+			maxVoters: 2,
+		},
+		{
+			name:        "missing voters promoted by ID",
+			servers:     []raft.Server{voter("self"), nonvoter("d"), nonvoter("c"), nonvoter("b")},
+			alive:       map[string]Role{"self": RoleVoter, "b": RoleVoter, "c": RoleVoter, "d": RoleVoter},
+			maxVoters:   3,
+			wantPromote: []string{"b", "c"},
+		},
+		{
+			name:        "unreachable nonvoter not promoted",
+			servers:     []raft.Server{voter("self"), nonvoter("b"), nonvoter("c")},
+			alive:       map[string]Role{"self": RoleVoter, "c": RoleVoter},
+			maxVoters:   3,
+			wantPromote: []string{"c"},
+		},
+		{
+			name:        "nonvoter role not promoted",
+			servers:     []raft.Server{voter("self"), nonvoter("b"), nonvoter("c")},
+			alive:       map[string]Role{"self": RoleVoter, "b": RoleNonvoter, "c": RoleWitness},
+			maxVoters:   3,
+			wantPromote: []string{"c"},
+		},
+		{
+			name:       "unreachable voters demoted first",
+			servers:    []raft.Server{voter("self"), voter("b"), voter("c"), voter("d")},
+			alive:      map[string]Role{"self": RoleVoter, "c": RoleVoter, "d": RoleVoter},
+			maxVoters:  2,
+			wantDemote: []string{"b", "d"},
+		},
+		{
+			name:       "self never demoted",
+			servers:    []raft.Server{voter("a"), voter("b"), voter("self")},
+			alive:      map[string]Role{"a": RoleVoter, "b": RoleVoter, "self": RoleVoter},
+			maxVoters:  1,
+			wantDemote: []string{"b", "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alive := map[string]nodeMeta{}
+			for id, role := range tt.alive {
+				alive[id] = nodeMeta{Role: role}
+			}
+			promote, demote := voterChanges(tt.servers, alive, "self", tt.maxVoters)
+			if got := raftServerIds(promote); !reflect.DeepEqual(got, tt.wantPromote) {
+				t.Errorf("promote = %v, want %v", got, tt.wantPromote)
+			}
+			if got := raftServerIds(demote); !reflect.DeepEqual(got, tt.wantDemote) {
+				t.Errorf("demote = %v, want %v", got, tt.wantDemote)
+			}
+		})
+	}
+}
+
+func TestClusterKeepsMaxVoters(t *testing.T) {
+	nodes := startTestCluster(t, 3, WithMaxVoters(2))
+	leader := leaderOf(t, nodes)
+	waitUntil(t, "the cluster has 2 voters and 1 nonvoter", func() bool {
+		future := leader.Raft.GetConfiguration()
+		if future.Error() != nil {
+			return false
+		}
+		suffrages := map[raft.ServerSuffrage]int{}
+		for _, server := range future.Configuration().Servers {
+			suffrages[server.Suffrage]++
+		}
+		return suffrages[raft.Voter] == 2 && suffrages[raft.Nonvoter] == 1
+	})
+}
+
+func raftServerIds(servers []raft.Server) []string {
+	var ids []string
+	for _, server := range servers {
+		ids = append(ids, string(server.ID))
+	}
+	return ids
+}